@@ -7,6 +7,7 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/logging"
 	"github.com/chipgata/github-actions-exporter/pkg/server"
 )
 
@@ -19,7 +20,14 @@ func main() {
 	app.Name = "github-actions-exporter"
 	app.Flags = config.InitConfiguration()
 	app.Version = version
+	app.Before = func(ctx *cli.Context) error {
+		return logging.Setup(config.LogLevel, config.LogFormat)
+	}
 	app.Action = server.RunServer
+	app.Commands = []*cli.Command{
+		server.CollectCommand(),
+		server.CheckAccessCommand(),
+	}
 
 	err := app.Run(os.Args)
 	if err != nil {
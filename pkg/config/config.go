@@ -14,14 +14,63 @@ var (
 		Organizations     cli.StringSlice
 		APIURL            string
 		CacheSizeBytes    int64
+		CacheDir          string
+		CacheDirSizeBytes int64
+		Retries           int
+		RetryMaxWait      int64
+		ProxyURL          string
+		NoProxy           string
+		CAFiles           cli.StringSlice
+		ClientCertFile    string
+		ClientKeyFile     string
+		RequestTimeout    int64
+		ConnectTimeout    int64
+
+		CheckRepoActivity   bool
+		RepoActivityRefresh int64
+	}
+	// OTLP - OpenTelemetry export configuration
+	OTLP struct {
+		Endpoint string
+		Protocol string
+		Insecure bool
+		Interval int64
+		Traces   bool
+	}
+	// Push - remote write / pushgateway output configuration
+	Push struct {
+		RemoteWriteURL      string
+		PushgatewayURL      string
+		PushgatewayJob      string
+		PushgatewayGrouping cli.StringSlice
+		ExternalLabels      cli.StringSlice
+		Username            string
+		Password            string
+		BearerToken         string
+		Retries             int
 	}
 	Metrics struct {
 		FetchWorkflowRunUsage bool
+		FetchArtifacts        bool
+		FetchCacheUsage       bool
+		FetchDeployments      bool
+		FetchJobQueue         bool
+		JobQueueRefresh       int64
+		WorkflowRunRetention  int64
 	}
-	Port           int
-	Debug          bool
-	EnterpriseName string
-	WorkflowFields string
+	Port                int
+	ListenAddress       string
+	WebConfigFile       string
+	DebugListenAddress  string
+	DebugWebConfigFile  string
+	LogLevel            string
+	LogFormat           string
+	KedaScalerPort      int
+	Debug               bool
+	EnterpriseName      string
+	WorkflowFields      string
+	DiscoveryConfigFile string
+	ConfigFile          string
 )
 
 // InitConfiguration - set configuration from env vars or command parameters
@@ -56,6 +105,25 @@ func InitConfiguration() []cli.Flag {
 			Usage:       "Exporter port",
 			Destination: &Port,
 		},
+		&cli.StringFlag{
+			Name:        "web_listen_address",
+			EnvVars:     []string{"WEB_LISTEN_ADDRESS"},
+			Usage:       "Address to listen on, host:port or unix:/path/to.sock. Defaults to 0.0.0.0 and the exporter port",
+			Destination: &ListenAddress,
+		},
+		&cli.StringFlag{
+			Name:        "web_config_file",
+			EnvVars:     []string{"WEB_CONFIG_FILE"},
+			Usage:       "Path to a YAML file with TLS and authentication settings of the HTTP server",
+			Destination: &WebConfigFile,
+		},
+		&cli.IntFlag{
+			Name:        "keda_scaler_port",
+			EnvVars:     []string{"KEDA_SCALER_PORT"},
+			Value:       0,
+			Usage:       "Port of the KEDA external scaler gRPC endpoint, disabled when 0. Needs fetch_job_queue",
+			Destination: &KedaScalerPort,
+		},
 		&cli.StringFlag{
 			Name:        "github_token",
 			Aliases:     []string{"gt"},
@@ -93,12 +161,153 @@ func InitConfiguration() []cli.Flag {
 			Usage:       "List all repositories you want get informations. Format <orga>/<repo>,<orga>/<repo2>,<orga>/<repo3> (like test/test)",
 			Destination: &Github.Repositories,
 		},
+		&cli.BoolFlag{
+			Name:        "check_repo_activity",
+			EnvVars:     []string{"CHECK_REPO_ACTIVITY"},
+			Usage:       "When true, repositories without workflows or with Actions disabled are marked inactive and not fetched",
+			Value:       false,
+			Destination: &Github.CheckRepoActivity,
+		},
+		&cli.Int64Flag{
+			Name:        "repo_activity_refresh",
+			EnvVars:     []string{"REPO_ACTIVITY_REFRESH"},
+			Value:       3600,
+			Usage:       "Refresh time of the repository activity check in sec",
+			Destination: &Github.RepoActivityRefresh,
+		},
+		&cli.StringFlag{
+			Name:        "otlp_endpoint",
+			EnvVars:     []string{"OTLP_ENDPOINT"},
+			Usage:       "host:port of an OTLP receiver to push metrics to, disabled when empty",
+			Destination: &OTLP.Endpoint,
+		},
+		&cli.StringFlag{
+			Name:        "otlp_protocol",
+			EnvVars:     []string{"OTLP_PROTOCOL"},
+			Value:       "grpc",
+			Usage:       "OTLP protocol, grpc or http/protobuf",
+			Destination: &OTLP.Protocol,
+		},
+		&cli.BoolFlag{
+			Name:        "otlp_insecure",
+			EnvVars:     []string{"OTLP_INSECURE"},
+			Usage:       "Disable TLS towards the OTLP receiver",
+			Destination: &OTLP.Insecure,
+		},
+		&cli.Int64Flag{
+			Name:        "otlp_interval",
+			EnvVars:     []string{"OTLP_INTERVAL"},
+			Value:       60,
+			Usage:       "Push interval of OTLP metrics in sec",
+			Destination: &OTLP.Interval,
+		},
+		&cli.BoolFlag{
+			Name:        "otlp_traces",
+			EnvVars:     []string{"OTLP_TRACES"},
+			Usage:       "When true, completed workflow runs are exported as traces to the OTLP endpoint",
+			Destination: &OTLP.Traces,
+		},
+		&cli.StringFlag{
+			Name:        "remote_write_url",
+			EnvVars:     []string{"REMOTE_WRITE_URL"},
+			Usage:       "Prometheus remote write url to push metrics to on every refresh, disabled when empty",
+			Destination: &Push.RemoteWriteURL,
+		},
+		&cli.StringSliceFlag{
+			Name:        "remote_write_external_labels",
+			EnvVars:     []string{"REMOTE_WRITE_EXTERNAL_LABELS"},
+			Usage:       "Labels added to every remote written series, to tell exporters writing to the same receiver apart. Format <label>=<value>,<label2>=<value2>",
+			Destination: &Push.ExternalLabels,
+		},
+		&cli.StringFlag{
+			Name:        "pushgateway_url",
+			EnvVars:     []string{"PUSHGATEWAY_URL"},
+			Usage:       "Pushgateway url to push metrics to on every refresh, disabled when empty",
+			Destination: &Push.PushgatewayURL,
+		},
+		&cli.StringFlag{
+			Name:        "pushgateway_job",
+			EnvVars:     []string{"PUSHGATEWAY_JOB"},
+			Value:       "github-actions-exporter",
+			Usage:       "Job name used on the pushgateway",
+			Destination: &Push.PushgatewayJob,
+		},
+		&cli.StringSliceFlag{
+			Name:        "pushgateway_grouping",
+			EnvVars:     []string{"PUSHGATEWAY_GROUPING"},
+			Usage:       "Grouping key used on the pushgateway. Format <label>=<value>,<label2>=<value2>",
+			Destination: &Push.PushgatewayGrouping,
+		},
+		&cli.StringFlag{
+			Name:        "push_username",
+			EnvVars:     []string{"PUSH_USERNAME"},
+			Usage:       "Basic auth username for remote write and pushgateway",
+			Destination: &Push.Username,
+		},
+		&cli.StringFlag{
+			Name:        "push_password",
+			EnvVars:     []string{"PUSH_PASSWORD"},
+			Usage:       "Basic auth password for remote write and pushgateway",
+			Destination: &Push.Password,
+		},
+		&cli.StringFlag{
+			Name:        "push_bearer_token",
+			EnvVars:     []string{"PUSH_BEARER_TOKEN"},
+			Usage:       "Bearer token for remote write and pushgateway, takes precedence over basic auth",
+			Destination: &Push.BearerToken,
+		},
+		&cli.IntFlag{
+			Name:        "push_retries",
+			EnvVars:     []string{"PUSH_RETRIES"},
+			Value:       3,
+			Usage:       "Number of retries, with exponential backoff, of a failed push",
+			Destination: &Push.Retries,
+		},
+		&cli.StringFlag{
+			Name:        "config_file",
+			EnvVars:     []string{"CONFIG_FILE"},
+			Usage:       "Path to a YAML file with github_orgas and github_repos, reloaded on change. Its values replace the flags and env vars",
+			Destination: &ConfigFile,
+		},
+		&cli.StringFlag{
+			Name:        "discovery_config",
+			EnvVars:     []string{"DISCOVERY_CONFIG"},
+			Usage:       "Path to a YAML file with include / exclude rules for repositories discovered from GITHUB_ORGAS",
+			Destination: &DiscoveryConfigFile,
+		},
 		&cli.BoolFlag{
 			Name:        "debug_profile",
 			EnvVars:     []string{"DEBUG_PROFILE"},
-			Usage:       "Expose pprof information on /debug/pprof/",
+			Usage:       "Serve pprof and runtime debug endpoints on the debug listen address",
 			Destination: &Debug,
 		},
+		&cli.StringFlag{
+			Name:        "debug_listen_address",
+			EnvVars:     []string{"DEBUG_LISTEN_ADDRESS"},
+			Value:       "localhost:9990",
+			Usage:       "Address of the debug listener, host:port or unix:/path/to.sock",
+			Destination: &DebugListenAddress,
+		},
+		&cli.StringFlag{
+			Name:        "debug_web_config_file",
+			EnvVars:     []string{"DEBUG_WEB_CONFIG_FILE"},
+			Usage:       "Path to a YAML file with TLS and authentication settings of the debug listener",
+			Destination: &DebugWebConfigFile,
+		},
+		&cli.StringFlag{
+			Name:        "log_level",
+			EnvVars:     []string{"LOG_LEVEL"},
+			Value:       "info",
+			Usage:       "Log level, debug, info, warn or error. Can be changed at runtime on the debug listener",
+			Destination: &LogLevel,
+		},
+		&cli.StringFlag{
+			Name:        "log_format",
+			EnvVars:     []string{"LOG_FORMAT"},
+			Value:       "text",
+			Usage:       "Log format, text or json",
+			Destination: &LogFormat,
+		},
 		&cli.StringFlag{
 			Name:        "enterprise_name",
 			EnvVars:     []string{"ENTERPRISE_NAME"},
@@ -120,12 +329,125 @@ func InitConfiguration() []cli.Flag {
 			Value:       false,
 			Destination: &Metrics.FetchWorkflowRunUsage,
 		},
+		&cli.BoolFlag{
+			Name:        "fetch_artifacts",
+			EnvVars:     []string{"FETCH_ARTIFACTS"},
+			Usage:       "When true, will page through the artifacts of each repository to export artifact storage metrics",
+			Value:       false,
+			Destination: &Metrics.FetchArtifacts,
+		},
+		&cli.BoolFlag{
+			Name:        "fetch_cache_usage",
+			EnvVars:     []string{"FETCH_CACHE_USAGE"},
+			Usage:       "When true, will fetch the Actions cache usage of each repository and organization",
+			Value:       false,
+			Destination: &Metrics.FetchCacheUsage,
+		},
+		&cli.BoolFlag{
+			Name:        "fetch_deployments",
+			EnvVars:     []string{"FETCH_DEPLOYMENTS"},
+			Usage:       "When true, will fetch deployments, deployment statuses and pending deployments of each repository",
+			Value:       false,
+			Destination: &Metrics.FetchDeployments,
+		},
+		&cli.BoolFlag{
+			Name:        "fetch_job_queue",
+			EnvVars:     []string{"FETCH_JOB_QUEUE"},
+			Usage:       "When true, will fetch the queued and in progress jobs of not yet completed workflow runs",
+			Value:       false,
+			Destination: &Metrics.FetchJobQueue,
+		},
+		&cli.Int64Flag{
+			Name:        "job_queue_refresh",
+			EnvVars:     []string{"JOB_QUEUE_REFRESH"},
+			Value:       15,
+			Usage:       "Refresh time of the queued and in progress jobs in sec",
+			Destination: &Metrics.JobQueueRefresh,
+		},
+		&cli.Int64Flag{
+			Name:        "workflow_run_retention",
+			EnvVars:     []string{"WORKFLOW_RUN_RETENTION"},
+			Value:       3600,
+			Usage:       "Time in sec completed workflow runs and their jobs stay exported after their last update. Runs created in the last 1hr are always exported",
+			Destination: &Metrics.WorkflowRunRetention,
+		},
 		&cli.Int64Flag{
 			Name:        "github_cache_size_bytes",
 			EnvVars:     []string{"GITHUB_CACHE_SIZE_BYTES"},
 			Value:       100 * 1024 * 1024,
-			Usage:       "Size of Github HTTP cache in bytes",
+			Usage:       "Size of Github HTTP cache in bytes, when kept in memory",
 			Destination: &Github.CacheSizeBytes,
 		},
+		&cli.StringFlag{
+			Name:        "github_cache_dir",
+			EnvVars:     []string{"GITHUB_CACHE_DIR"},
+			Usage:       "Directory of a persistent Github HTTP cache, kept across restarts. The cache is in memory when not set",
+			Destination: &Github.CacheDir,
+		},
+		&cli.Int64Flag{
+			Name:        "github_cache_dir_size_bytes",
+			EnvVars:     []string{"GITHUB_CACHE_DIR_SIZE_BYTES"},
+			Value:       100 * 1024 * 1024,
+			Usage:       "Size of the persistent Github HTTP cache in bytes, when github_cache_dir is set",
+			Destination: &Github.CacheDirSizeBytes,
+		},
+		&cli.IntFlag{
+			Name:        "github_retries",
+			EnvVars:     []string{"GITHUB_RETRIES"},
+			Value:       3,
+			Usage:       "Number of retries of a Github API request failing with a secondary rate limit, a 502 / 503 / 504 or a timeout",
+			Destination: &Github.Retries,
+		},
+		&cli.Int64Flag{
+			Name:        "github_retry_max_wait",
+			EnvVars:     []string{"GITHUB_RETRY_MAX_WAIT"},
+			Value:       300,
+			Usage:       "Longest wait in sec before a retry of a Github API request, requests asked to wait longer are not retried",
+			Destination: &Github.RetryMaxWait,
+		},
+		&cli.StringFlag{
+			Name:        "github_proxy_url",
+			EnvVars:     []string{"GITHUB_PROXY_URL"},
+			Usage:       "URL of the proxy of the Github API requests. HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used when not set",
+			Destination: &Github.ProxyURL,
+		},
+		&cli.StringFlag{
+			Name:        "github_no_proxy",
+			EnvVars:     []string{"GITHUB_NO_PROXY"},
+			Usage:       "Comma separated hosts, domains, IPs and CIDRs reached without github_proxy_url, same format as NO_PROXY",
+			Destination: &Github.NoProxy,
+		},
+		&cli.StringSliceFlag{
+			Name:        "github_ca_file",
+			EnvVars:     []string{"GITHUB_CA_FILE"},
+			Usage:       "PEM CA bundle trusted for the Github API in addition to the system CAs, can be repeated",
+			Destination: &Github.CAFiles,
+		},
+		&cli.StringFlag{
+			Name:        "github_client_cert_file",
+			EnvVars:     []string{"GITHUB_CLIENT_CERT_FILE"},
+			Usage:       "PEM client certificate presented to the Github API, with github_client_key_file",
+			Destination: &Github.ClientCertFile,
+		},
+		&cli.StringFlag{
+			Name:        "github_client_key_file",
+			EnvVars:     []string{"GITHUB_CLIENT_KEY_FILE"},
+			Usage:       "PEM private key of github_client_cert_file",
+			Destination: &Github.ClientKeyFile,
+		},
+		&cli.Int64Flag{
+			Name:        "github_request_timeout",
+			EnvVars:     []string{"GITHUB_REQUEST_TIMEOUT"},
+			Value:       60,
+			Usage:       "Timeout in sec of each Github API request, response body included. 0 to disable",
+			Destination: &Github.RequestTimeout,
+		},
+		&cli.Int64Flag{
+			Name:        "github_connect_timeout",
+			EnvVars:     []string{"GITHUB_CONNECT_TIMEOUT"},
+			Value:       10,
+			Usage:       "Timeout in sec of the connection, TLS handshake included, to the Github API or the proxy. 0 for the Go defaults",
+			Destination: &Github.ConnectTimeout,
+		},
 	}
 }
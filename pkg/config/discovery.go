@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// DiscoveryRules - include / exclude rules applied to repositories discovered for an organization
+type DiscoveryRules struct {
+	IncludeNames    []string       `yaml:"include_names"`
+	ExcludeNames    []string       `yaml:"exclude_names"`
+	IncludeTopics   []string       `yaml:"include_topics"`
+	ExcludeTopics   []string       `yaml:"exclude_topics"`
+	Visibility      []string       `yaml:"visibility"`
+	ExcludeArchived bool           `yaml:"exclude_archived"`
+	ExcludeForks    bool           `yaml:"exclude_forks"`
+	MaxPushAge      model.Duration `yaml:"max_push_age"`
+
+	includeNames []*regexp.Regexp
+	excludeNames []*regexp.Regexp
+}
+
+// DiscoveryConfig - repository discovery configuration, loaded from the discovery config file
+type DiscoveryConfig struct {
+	Default       DiscoveryRules            `yaml:"default"`
+	Organizations map[string]DiscoveryRules `yaml:"organizations"`
+}
+
+// readDiscoveryConfig - read and validate a discovery config file, empty rules when file is not set
+func readDiscoveryConfig(file string) (DiscoveryConfig, error) {
+	if file == "" {
+		return DiscoveryConfig{}, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return DiscoveryConfig{}, fmt.Errorf("reading discovery config failed: %v", err)
+	}
+	return ParseDiscoveryConfig(content)
+}
+
+// ParseDiscoveryConfig - parse a discovery config and compile its name rules
+func ParseDiscoveryConfig(content []byte) (DiscoveryConfig, error) {
+	var discovery DiscoveryConfig
+	if err := yaml.Unmarshal(content, &discovery); err != nil {
+		return discovery, fmt.Errorf("parsing discovery config failed: %v", err)
+	}
+	if err := discovery.Default.compile(); err != nil {
+		return discovery, fmt.Errorf("default discovery rules: %v", err)
+	}
+	for orga, rules := range discovery.Organizations {
+		if err := rules.compile(); err != nil {
+			return discovery, fmt.Errorf("discovery rules for %s: %v", orga, err)
+		}
+		discovery.Organizations[orga] = rules
+	}
+	return discovery, nil
+}
+
+// RulesFor - return the discovery rules of an organization, falling back on the default rules
+func (d DiscoveryConfig) RulesFor(orga string) DiscoveryRules {
+	if rules, ok := d.Organizations[orga]; ok {
+		return rules
+	}
+	return d.Default
+}
+
+func (r *DiscoveryRules) compile() error {
+	var err error
+	if r.includeNames, err = compileAll(r.IncludeNames); err != nil {
+		return err
+	}
+	if r.excludeNames, err = compileAll(r.ExcludeNames); err != nil {
+		return err
+	}
+	return nil
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %v", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// MatchName - true if name matches the include patterns (if any) and none of the exclude patterns
+func (r DiscoveryRules) MatchName(name string) bool {
+	if len(r.includeNames) > 0 && !matchAny(r.includeNames, name) {
+		return false
+	}
+	return !matchAny(r.excludeNames, name)
+}
+
+// MatchTopics - true if topics contain one of the include topics (if any) and none of the exclude topics
+func (r DiscoveryRules) MatchTopics(topics []string) bool {
+	if len(r.IncludeTopics) > 0 && !containsAny(r.IncludeTopics, topics) {
+		return false
+	}
+	return !containsAny(r.ExcludeTopics, topics)
+}
+
+// MatchVisibility - true if no visibility rule is set or visibility is one of the allowed values
+func (r DiscoveryRules) MatchVisibility(visibility string) bool {
+	return len(r.Visibility) == 0 || containsAny(r.Visibility, []string{visibility})
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(wanted []string, values []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if w == v {
+				return true
+			}
+		}
+	}
+	return false
+}
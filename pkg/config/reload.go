@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig - settings of the config file, applied at start and on every reload.
+// Keys are named after the flags; a key set in the file replaces the flag / env var value.
+type FileConfig struct {
+	Organizations *[]string `yaml:"github_orgas"`
+	Repositories  *[]string `yaml:"github_repos"`
+}
+
+// Reloadable - every setting that can change without a restart
+type Reloadable struct {
+	Organizations []string
+	Repositories  []string
+	Discovery     DiscoveryConfig
+	Web           WebConfig
+	DebugWeb      WebConfig
+}
+
+// current - settings in use, replaced as a whole on reload
+var current struct {
+	sync.RWMutex
+	Reloadable
+}
+
+// ParseConfigFile - parse a config file, unknown keys are rejected
+func ParseConfigFile(content []byte) (FileConfig, error) {
+	var file FileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return file, fmt.Errorf("parsing config file failed: %v", err)
+	}
+	return file, nil
+}
+
+// ReadReloadable - read the config file, the discovery config and the web configs on top of the
+// flags, and validate them, without applying anything
+func ReadReloadable() (Reloadable, error) {
+	r := Reloadable{
+		Organizations: Github.Organizations.Value(),
+		Repositories:  Github.Repositories.Value(),
+	}
+
+	if ConfigFile != "" {
+		content, err := os.ReadFile(ConfigFile)
+		if err != nil {
+			return r, fmt.Errorf("reading config file failed: %v", err)
+		}
+		file, err := ParseConfigFile(content)
+		if err != nil {
+			return r, err
+		}
+		if file.Organizations != nil {
+			r.Organizations = *file.Organizations
+		}
+		if file.Repositories != nil {
+			r.Repositories = *file.Repositories
+		}
+	}
+	for _, repo := range r.Repositories {
+		if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return r, fmt.Errorf("invalid repository %q, expected <orga>/<repo>", repo)
+		}
+	}
+
+	var err error
+	if r.Discovery, err = readDiscoveryConfig(DiscoveryConfigFile); err != nil {
+		return r, err
+	}
+	if r.Web, err = readWebConfig(WebConfigFile); err != nil {
+		return r, err
+	}
+	if r.DebugWeb, err = readWebConfig(DebugWebConfigFile); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// Apply - replace the settings in use
+func Apply(r Reloadable) {
+	current.Lock()
+	current.Reloadable = r
+	current.Unlock()
+}
+
+// validateFlags - reject flag values the collectors can't run with. Flags are not reloadable,
+// they are only checked by Load.
+func validateFlags() error {
+	if Metrics.JobQueueRefresh <= 0 {
+		return fmt.Errorf("invalid job_queue_refresh %d, must be positive", Metrics.JobQueueRefresh)
+	}
+	return nil
+}
+
+// Load - validate the flags, then read, validate and apply the reloadable settings
+func Load() error {
+	if err := validateFlags(); err != nil {
+		return err
+	}
+	r, err := ReadReloadable()
+	if err != nil {
+		return err
+	}
+	Apply(r)
+	return nil
+}
+
+// Organizations - organizations to monitor
+func Organizations() []string {
+	current.RLock()
+	defer current.RUnlock()
+	return append([]string{}, current.Organizations...)
+}
+
+// Repositories - repositories to monitor, empty to monitor every repository of the organizations
+func Repositories() []string {
+	current.RLock()
+	defer current.RUnlock()
+	return append([]string{}, current.Repositories...)
+}
+
+// Discovery - repository discovery rules, empty when no discovery config file is set
+func Discovery() DiscoveryConfig {
+	current.RLock()
+	defer current.RUnlock()
+	return current.Discovery
+}
+
+// Web - web config of the HTTP server, empty when no web config file is set
+func Web() WebConfig {
+	current.RLock()
+	defer current.RUnlock()
+	return current.Web
+}
+
+// DebugWeb - web config of the debug listener, empty when no debug web config file is set
+func DebugWeb() WebConfig {
+	current.RLock()
+	defer current.RUnlock()
+	return current.DebugWeb
+}
+
+// WatchedFiles - files read on reload, a change in one of them triggers a reload
+func WatchedFiles() []string {
+	var files []string
+	for _, file := range []string{ConfigFile, DiscoveryConfigFile, WebConfigFile, DebugWebConfigFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
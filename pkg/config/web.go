@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// TLSServerConfig - TLS settings of the HTTP server
+type TLSServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	MinVersion     string `yaml:"min_version"`
+}
+
+// WebConfig - TLS and authentication of the HTTP server, loaded from the web config file.
+// Follows the format of the Prometheus exporter-toolkit, with bearer tokens on top.
+type WebConfig struct {
+	TLSServerConfig *TLSServerConfig  `yaml:"tls_server_config"`
+	BasicAuthUsers  map[string]string `yaml:"basic_auth_users"`
+	BearerTokens    []string          `yaml:"bearer_tokens"`
+}
+
+// clientAuthTypes - accepted values of client_auth_type
+var clientAuthTypes = map[string]bool{
+	"":                           true,
+	"NoClientCert":               true,
+	"RequestClientCert":          true,
+	"RequireAnyClientCert":       true,
+	"VerifyClientCertIfGiven":    true,
+	"RequireAndVerifyClientCert": true,
+}
+
+// minVersions - accepted values of min_version
+var minVersions = map[string]bool{"": true, "TLS10": true, "TLS11": true, "TLS12": true, "TLS13": true}
+
+func readWebConfig(file string) (WebConfig, error) {
+	if file == "" {
+		return WebConfig{}, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return WebConfig{}, fmt.Errorf("reading web config failed: %v", err)
+	}
+	return ParseWebConfig(content)
+}
+
+// ParseWebConfig - parse and validate a web config
+func ParseWebConfig(content []byte) (WebConfig, error) {
+	var web WebConfig
+	if err := yaml.Unmarshal(content, &web); err != nil {
+		return web, fmt.Errorf("parsing web config failed: %v", err)
+	}
+
+	if tlsConfig := web.TLSServerConfig; tlsConfig != nil {
+		if tlsConfig.CertFile == "" || tlsConfig.KeyFile == "" {
+			return web, fmt.Errorf("web config: tls_server_config needs both cert_file and key_file")
+		}
+		if !clientAuthTypes[tlsConfig.ClientAuthType] {
+			return web, fmt.Errorf("web config: invalid client_auth_type %q", tlsConfig.ClientAuthType)
+		}
+		if (tlsConfig.ClientAuthType == "RequireAndVerifyClientCert" || tlsConfig.ClientAuthType == "VerifyClientCertIfGiven") && tlsConfig.ClientCAFile == "" {
+			return web, fmt.Errorf("web config: client_auth_type %s needs client_ca_file", tlsConfig.ClientAuthType)
+		}
+		if !minVersions[tlsConfig.MinVersion] {
+			return web, fmt.Errorf("web config: invalid min_version %q", tlsConfig.MinVersion)
+		}
+	}
+
+	for user, hash := range web.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return web, fmt.Errorf("web config: password of user %s is not a bcrypt hash: %v", user, err)
+		}
+	}
+	for _, token := range web.BearerTokens {
+		if token == "" {
+			return web, fmt.Errorf("web config: empty bearer token")
+		}
+	}
+	return web, nil
+}
+
+// AuthEnabled - true if basic auth users or bearer tokens are configured
+func (w WebConfig) AuthEnabled() bool {
+	return len(w.BasicAuthUsers) > 0 || len(w.BearerTokens) > 0
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level - current log level, can be changed at runtime
+var Level = new(slog.LevelVar)
+
+// repeatInterval - an error repeated under the same key is logged at most once per interval
+const repeatInterval = time.Minute
+
+// repeated - last time each error key was logged, and how many times it was suppressed since.
+// Keys not logged for repeatInterval are pruned, keys can be unbounded (e.g. include a run id).
+var repeated = struct {
+	sync.Mutex
+	errors map[string]*repeatedError
+	pruned time.Time
+}{errors: map[string]*repeatedError{}}
+
+type repeatedError struct {
+	logged     time.Time
+	suppressed int
+}
+
+// ParseLevel - parse one of debug, info, warn or error
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return l, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", level)
+	}
+	return l, nil
+}
+
+// SetLevel - change the log level
+func SetLevel(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	Level.Set(l)
+	return nil
+}
+
+// Setup - log to stderr in the given format, text or json, at the given level.
+// The standard log package goes through the same handler, at info level.
+func Setup(level string, format string) error {
+	if err := SetLevel(level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: Level}
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// ErrorLimited - log an error, unless one with the same key was logged less than a minute ago.
+// The number of suppressed repeats is added to the next message logged for the key.
+func ErrorLimited(key string, msg string, args ...any) {
+	now := time.Now()
+	repeated.Lock()
+	r, ok := repeated.errors[key]
+	if !ok {
+		prune(now)
+		r = &repeatedError{}
+		repeated.errors[key] = r
+	}
+	if now.Sub(r.logged) < repeatInterval {
+		r.suppressed++
+		repeated.Unlock()
+		return
+	}
+	suppressed := r.suppressed
+	r.logged, r.suppressed = now, 0
+	repeated.Unlock()
+
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	slog.Error(msg, args...)
+}
+
+// prune - forget the keys last logged more than repeatInterval ago, at most once per interval.
+// Their next error is logged anyway, only the count of suppressed repeats is lost.
+// repeated must be locked.
+func prune(now time.Time) {
+	if now.Sub(repeated.pruned) < repeatInterval {
+		return
+	}
+	for key, r := range repeated.errors {
+		if now.Sub(r.logged) >= repeatInterval {
+			delete(repeated.errors, key)
+		}
+	}
+	repeated.pruned = now
+}
@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorLimitedPrunesOldKeys(t *testing.T) {
+	Level.Set(100) // keep the test output quiet
+
+	for i := 0; i < 10; i++ {
+		ErrorLimited(fmt.Sprint("run ", i), "failed")
+	}
+	ErrorLimited("run 0", "failed")
+	if got := len(repeated.errors); got != 10 {
+		t.Fatalf("%d keys, want 10", got)
+	}
+	if r := repeated.errors["run 0"]; r.suppressed != 1 {
+		t.Errorf("run 0 suppressed %d times, want 1", r.suppressed)
+	}
+
+	// Past the interval, inserting a new key forgets the old ones
+	for key, r := range repeated.errors {
+		if key != "run 9" {
+			r.logged = r.logged.Add(-2 * repeatInterval)
+		}
+	}
+	repeated.pruned = repeated.pruned.Add(-2 * repeatInterval)
+	ErrorLimited("run 10", "failed")
+	if got := len(repeated.errors); got != 2 {
+		t.Errorf("%d keys after pruning, want 2 (run 9 and run 10)", got)
+	}
+	if _, ok := repeated.errors["run 9"]; !ok {
+		t.Error("run 9, logged less than an interval ago, was pruned")
+	}
+	if time.Since(repeated.pruned) > time.Second {
+		t.Error("prune time not updated")
+	}
+}
@@ -0,0 +1,264 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// accessCheck - result of a single Github API call made by CheckAccess
+type accessCheck struct {
+	scope     string
+	endpoint  string
+	collector string
+	required  bool
+	result    string
+	detail    string
+}
+
+// accessChecker - runs the Github API calls of the collectors and keeps their results
+type accessChecker struct {
+	checks []accessCheck
+}
+
+// collectorEnabled - true if the named collector runs with the current configuration
+func collectorEnabled(name string) bool {
+	for _, c := range collectors {
+		if c.name == name {
+			return c.enabled()
+		}
+	}
+	return false
+}
+
+// check - make a call and record whether it succeeded. Calls of collectors that are not
+// enabled are still made, but are not required.
+func (a *accessChecker) check(scope string, endpoint string, collector string, call func() (*github.Response, error)) bool {
+	result := accessCheck{
+		scope:     scope,
+		endpoint:  endpoint,
+		collector: collector,
+		required:  collectorEnabled(collector),
+		result:    "ok",
+	}
+	resp, err := call()
+	if err != nil {
+		var errResp *github.ErrorResponse
+		switch {
+		case errors.As(err, &errResp) && isPermissionStatus(errResp.Response.StatusCode):
+			result.result = "missing permission"
+			result.detail = fmt.Sprintf("%d %s", errResp.Response.StatusCode, errResp.Message)
+		default:
+			result.result = "error"
+			result.detail = err.Error()
+		}
+	}
+	if resp != nil && result.result != "ok" {
+		// Github tells which scopes (PAT) or permissions (App) would have been accepted
+		if accepted := resp.Header.Get("X-Accepted-OAuth-Scopes"); accepted != "" {
+			result.detail += ", accepted scopes: " + accepted
+		}
+		if accepted := resp.Header.Get("X-Accepted-GitHub-Permissions"); accepted != "" {
+			result.detail += ", accepted permissions: " + accepted
+		}
+	}
+	a.checks = append(a.checks, result)
+	return result.result == "ok"
+}
+
+// isPermissionStatus - Github answers 404 instead of 403 on resources the token can't see
+func isPermissionStatus(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound
+}
+
+// failed - number of required calls that did not succeed
+func (a *accessChecker) failed() int {
+	failed := 0
+	for _, c := range a.checks {
+		if c.required && c.result != "ok" {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (a *accessChecker) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tENDPOINT\tCOLLECTOR\tREQUIRED\tRESULT\tDETAIL")
+	for _, c := range a.checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\n", c.scope, c.endpoint, c.collector, c.required, c.result, c.detail)
+	}
+	tw.Flush()
+}
+
+// printCredentials - report the kind of credentials, and for a PAT its scopes and expiry
+func printCredentials(w io.Writer) error {
+	if len(config.Github.Token) == 0 {
+		fmt.Fprintf(w, "Authenticated as Github App %d, installation %d. Installation tokens are refreshed automatically.\n", config.Github.AppID, config.Github.AppInstallationID)
+		return nil
+	}
+
+	req, err := client.NewRequest("GET", "rate_limit", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(context.Background(), req, nil)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %v", err)
+	}
+	scopes, ok := resp.Header["X-Oauth-Scopes"]
+	switch {
+	case !ok:
+		// Fine-grained tokens have no scopes, their permissions only show up in the checks below
+		fmt.Fprintln(w, "Authenticated with a fine-grained token (no OAuth scopes).")
+	case strings.TrimSpace(strings.Join(scopes, ",")) == "":
+		fmt.Fprintln(w, "Authenticated with a classic token without any scope.")
+	default:
+		fmt.Fprintf(w, "Authenticated with a classic token, scopes: %s\n", strings.Join(scopes, ","))
+	}
+	if expiry := resp.Header.Get("GitHub-Authentication-Token-Expiration"); expiry != "" {
+		fmt.Fprintf(w, "Token expires at %s\n", expiry)
+	} else {
+		fmt.Fprintln(w, "Token has no expiry.")
+	}
+	return nil
+}
+
+// checkRepoAccess - make the repository level calls of the collectors on a repository
+func (a *accessChecker) checkRepoAccess(owner string, repo string) {
+	scope := owner + "/" + repo
+
+	a.check(scope, "ListRunners", "runners", func() (*github.Response, error) {
+		_, resp, err := client.Actions.ListRunners(context.Background(), owner, repo, &github.ListOptions{PerPage: 1})
+		return resp, err
+	})
+
+	var runs *github.WorkflowRuns
+	a.check(scope, "ListRepositoryWorkflowRuns", "workflow_runs", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		runs, resp, err = client.Actions.ListRepositoryWorkflowRuns(context.Background(), owner, repo, &github.ListWorkflowRunsOptions{ListOptions: github.ListOptions{PerPage: 1}})
+		return resp, err
+	})
+	if runs != nil && len(runs.WorkflowRuns) > 0 {
+		runId := runs.WorkflowRuns[0].GetID()
+		a.check(scope, "ListWorkflowJobs", "workflow_runs", func() (*github.Response, error) {
+			_, resp, err := client.Actions.ListWorkflowJobs(context.Background(), owner, repo, runId, &github.ListWorkflowJobsOptions{ListOptions: github.ListOptions{PerPage: 1}})
+			return resp, err
+		})
+		if config.Metrics.FetchWorkflowRunUsage {
+			a.check(scope, "GetWorkflowRunUsageByID", "workflow_runs", func() (*github.Response, error) {
+				_, resp, err := client.Actions.GetWorkflowRunUsageByID(context.Background(), owner, repo, runId)
+				return resp, err
+			})
+		}
+		a.check(scope, "GetPendingDeployments", "deployments", func() (*github.Response, error) {
+			return getDirect(fmt.Sprintf("repos/%v/%v/actions/runs/%v/pending_deployments", owner, repo, runId), &[]*pendingDeployment{})
+		})
+	}
+
+	a.check(scope, "ListArtifacts", "artifacts", func() (*github.Response, error) {
+		_, resp, err := client.Actions.ListArtifacts(context.Background(), owner, repo, &github.ListOptions{PerPage: 1})
+		return resp, err
+	})
+	a.check(scope, "GetCacheUsage", "cache_usage", func() (*github.Response, error) {
+		return getDirect(fmt.Sprintf("repos/%v/%v/actions/cache/usage", owner, repo), &repoCacheUsage{})
+	})
+	a.check(scope, "ListDeployments", "deployments", func() (*github.Response, error) {
+		_, resp, err := client.Repositories.ListDeployments(context.Background(), owner, repo, &github.DeploymentsListOptions{ListOptions: github.ListOptions{PerPage: 1}})
+		return resp, err
+	})
+	if config.Github.CheckRepoActivity {
+		a.check(scope, "GetActionsPermissions", "repositories", func() (*github.Response, error) {
+			_, resp, err := client.Repositories.GetActionsPermissions(context.Background(), owner, repo)
+			return resp, err
+		})
+		a.check(scope, "ListWorkflows", "repositories", func() (*github.Response, error) {
+			_, resp, err := client.Actions.ListWorkflows(context.Background(), owner, repo, &github.ListOptions{PerPage: 1})
+			return resp, err
+		})
+	}
+}
+
+// getDirect - GET an endpoint not available in go-github v45 and decode the response into v
+func getDirect(endpoint string, v interface{}) (*github.Response, error) {
+	req, err := client.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(context.Background(), req, v)
+}
+
+// CheckAccess - authenticate, then call each endpoint needed by the collectors for every
+// configured organization and repository, and print which calls succeed.
+// When repositories are discovered from an organization, the first one is used as a sample.
+// Returns an error if a call needed by an enabled collector failed.
+func CheckAccess(w io.Writer) error {
+	if err := initClient(); err != nil {
+		return err
+	}
+	if err := printCredentials(w); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	a := &accessChecker{}
+	a.check("-", "RateLimits", "ratelimit", func() (*github.Response, error) {
+		_, resp, err := client.RateLimits(context.Background())
+		return resp, err
+	})
+
+	for _, orga := range config.Organizations() {
+		var repos []*github.Repository
+		a.check(orga, "ListByOrg", "repositories", func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			repos, resp, err = client.Repositories.ListByOrg(context.Background(), orga, &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 1}})
+			return resp, err
+		})
+		a.check(orga, "ListOrganizationRunners", "runners_organization", func() (*github.Response, error) {
+			_, resp, err := client.Actions.ListOrganizationRunners(context.Background(), orga, &github.ListOptions{PerPage: 1})
+			return resp, err
+		})
+		a.check(orga, "ListOrganizationRunnerGroups", "runners_organization", func() (*github.Response, error) {
+			_, resp, err := client.Actions.ListOrganizationRunnerGroups(context.Background(), orga, &github.ListOrgRunnerGroupOptions{ListOptions: github.ListOptions{PerPage: 1}})
+			return resp, err
+		})
+		a.check(orga, "GetCacheUsage", "cache_usage", func() (*github.Response, error) {
+			return getDirect(fmt.Sprintf("orgs/%v/actions/cache/usage", orga), &orgCacheUsage{})
+		})
+		if len(config.Repositories()) == 0 && len(repos) > 0 {
+			a.checkRepoAccess(orga, repos[0].GetName())
+		}
+	}
+
+	for _, repo := range config.Repositories() {
+		r := strings.Split(repo, "/")
+		if len(r) != 2 {
+			return fmt.Errorf("invalid repository %q, expected <orga>/<repo>", repo)
+		}
+		a.checkRepoAccess(r[0], r[1])
+	}
+
+	if config.EnterpriseName != "" {
+		a.check(config.EnterpriseName, "ListRunners", "runners_enterprise", func() (*github.Response, error) {
+			_, resp, err := client.Enterprise.ListRunners(context.Background(), config.EnterpriseName, &github.ListOptions{PerPage: 1})
+			return resp, err
+		})
+	}
+
+	a.print(w)
+
+	if failed := a.failed(); failed > 0 {
+		return fmt.Errorf("%d required Github API calls failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// collector - a periodic fetch from the Github API
+type collector struct {
+	name    string
+	enabled func() bool
+	refresh func() time.Duration
+	// collect - fill the gauges, returns an error if the cycle is incomplete.
+	// ctx carries the collector name, see collectorName.
+	collect func(ctx context.Context) error
+	// gauges - snapshot gauges filled by collect, published only once a cycle succeeded
+	gauges func() []*snapshotGaugeVec
+	// trigger - collect now instead of waiting for the next refresh, the channel is closed once done
+	trigger chan chan struct{}
+
+	mu           sync.Mutex
+	lastCollect  time.Time
+	lastDuration time.Duration
+	lastSuccess  time.Time
+	stale        bool
+}
+
+type collectorKey struct{}
+
+// withCollector - context of the Github API calls of a collector
+func withCollector(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, collectorKey{}, name)
+}
+
+// collectorName - name of the collector making the Github API calls of ctx, empty outside of a collector
+func collectorName(ctx context.Context) string {
+	name, _ := ctx.Value(collectorKey{}).(string)
+	return name
+}
+
+// cycleListeners - called each time a collector publishes a cycle, see OnCycle
+var cycleListeners struct {
+	sync.Mutex
+	listeners []func(collector string)
+}
+
+// OnCycle - call f with the collector name each time a collector publishes its metrics, after a
+// complete or partial cycle. f is called from the collector goroutine and must not block.
+func OnCycle(f func(collector string)) {
+	cycleListeners.Lock()
+	cycleListeners.listeners = append(cycleListeners.listeners, f)
+	cycleListeners.Unlock()
+}
+
+// notifyCycle - call the OnCycle listeners
+func notifyCycle(collector string) {
+	cycleListeners.Lock()
+	defer cycleListeners.Unlock()
+	for _, f := range cycleListeners.listeners {
+		f(collector)
+	}
+}
+
+func always() bool { return true }
+
+func githubRefresh() time.Duration {
+	return time.Duration(config.Github.Refresh) * time.Second
+}
+
+// collectors - every collector, the repositories collector first as the others iterate on its result
+var collectors = []*collector{
+	{
+		name:    "repositories",
+		enabled: always,
+		refresh: func() time.Duration { return 5 * githubRefresh() },
+		collect: getRepositoriesFromGithub,
+		gauges:  func() []*snapshotGaugeVec { return []*snapshotGaugeVec{repositoryActivityGauge} },
+	},
+	{
+		name:    "runners",
+		enabled: always,
+		refresh: githubRefresh,
+		collect: getRunnersFromGithub,
+		gauges:  func() []*snapshotGaugeVec { return []*snapshotGaugeVec{runnersGauge} },
+	},
+	{
+		name:    "runners_organization",
+		enabled: always,
+		refresh: githubRefresh,
+		collect: getRunnersOrganizationFromGithub,
+		gauges:  func() []*snapshotGaugeVec { return []*snapshotGaugeVec{runnersOrganizationGauge} },
+	},
+	{
+		name:    "workflow_runs",
+		enabled: always,
+		refresh: githubRefresh,
+		collect: getWorkflowRunsFromGithub,
+		gauges: func() []*snapshotGaugeVec {
+			return []*snapshotGaugeVec{workflowRunStatusGauge, workflowRunDurationGauge, workflowJobDurationTotalGauge, workflowJobStatusCounter}
+		},
+	},
+	{
+		name:    "runners_enterprise",
+		enabled: func() bool { return config.EnterpriseName != "" },
+		refresh: githubRefresh,
+		collect: getRunnersEnterpriseFromGithub,
+		gauges:  func() []*snapshotGaugeVec { return []*snapshotGaugeVec{runnersEnterpriseGauge} },
+	},
+	{
+		name:    "ratelimit",
+		enabled: always,
+		refresh: githubRefresh,
+		collect: getRateLimitFromGithub,
+		gauges:  func() []*snapshotGaugeVec { return []*snapshotGaugeVec{rateLimitGauge} },
+	},
+	{
+		name:    "artifacts",
+		enabled: func() bool { return config.Metrics.FetchArtifacts },
+		refresh: githubRefresh,
+		collect: getArtifactsFromGithub,
+		gauges: func() []*snapshotGaugeVec {
+			return []*snapshotGaugeVec{artifactCountGauge, artifactSizeGauge, artifactStatusGauge, artifactOldestAgeGauge}
+		},
+	},
+	{
+		name:    "cache_usage",
+		enabled: func() bool { return config.Metrics.FetchCacheUsage },
+		refresh: githubRefresh,
+		collect: getCacheUsageFromGithub,
+		gauges: func() []*snapshotGaugeVec {
+			return []*snapshotGaugeVec{cacheUsageCountGauge, cacheUsageSizeGauge, cacheUsageOrganizationCountGauge, cacheUsageOrganizationSizeGauge}
+		},
+	},
+	{
+		name:    "deployments",
+		enabled: func() bool { return config.Metrics.FetchDeployments },
+		refresh: githubRefresh,
+		collect: getDeploymentsFromGithub,
+		gauges: func() []*snapshotGaugeVec {
+			return []*snapshotGaugeVec{deploymentStatusGauge, deploymentApprovalWaitGauge, deploymentPendingGauge, deploymentPendingWaitGauge}
+		},
+	},
+	{
+		name:    "job_queue",
+		enabled: func() bool { return config.Metrics.FetchJobQueue },
+		refresh: func() time.Duration { return time.Duration(config.Metrics.JobQueueRefresh) * time.Second },
+		collect: getJobQueueFromGithub,
+		gauges:  func() []*snapshotGaugeVec { return []*snapshotGaugeVec{jobQueueGauge, jobQueueOldestAgeGauge} },
+	},
+}
+
+// CollectorStatus - state of a collector, as shown on the status page
+type CollectorStatus struct {
+	Name         string
+	Enabled      bool
+	Refresh      time.Duration
+	LastCollect  time.Time
+	LastDuration time.Duration
+	LastSuccess  time.Time
+	Stale        bool
+}
+
+// Collectors - state of every collector, in order
+func Collectors() []CollectorStatus {
+	result := make([]CollectorStatus, 0, len(collectors))
+	for _, c := range collectors {
+		c.mu.Lock()
+		result = append(result, CollectorStatus{
+			Name:         c.name,
+			Enabled:      c.enabled(),
+			Refresh:      c.refresh(),
+			LastCollect:  c.lastCollect,
+			LastDuration: c.lastDuration,
+			LastSuccess:  c.lastSuccess,
+			Stale:        c.stale,
+		})
+		c.mu.Unlock()
+	}
+	return result
+}
+
+// run - collect once and keep track of when and how long. The gauges of the collector are
+// published if the cycle succeeded, or if only some repositories or organizations failed
+// (partialError), their series being carried over, and the OnCycle listeners are called.
+// Otherwise the previous snapshot is kept. The collector is marked stale until a cycle succeeds.
+func (c *collector) run() error {
+	start := time.Now()
+	err := c.collect(withCollector(context.Background(), c.name))
+	duration := time.Since(start)
+	incomplete := errors.As(err, &partialError{})
+
+	c.mu.Lock()
+	c.lastCollect = start
+	c.lastDuration = duration
+	c.stale = err != nil
+	if err == nil {
+		c.lastSuccess = start
+	}
+	c.mu.Unlock()
+
+	if err != nil && !incomplete {
+		slog.Warn("collection failed, keeping the previous metrics", "collector", c.name, "duration", duration.String(), "error", err)
+		collectorStaleGauge.WithLabelValues(c.name).Set(1)
+		return err
+	}
+	for _, gauge := range c.gauges() {
+		gauge.publish()
+	}
+	notifyCycle(c.name)
+	if incomplete {
+		slog.Warn("collection incomplete, keeping the previous metrics of the failed repositories and organizations", "collector", c.name, "duration", duration.String(), "error", err)
+		collectorStaleGauge.WithLabelValues(c.name).Set(1)
+		return err
+	}
+	slog.Debug("collection done", "collector", c.name, "duration", duration.String())
+	collectorStaleGauge.WithLabelValues(c.name).Set(0)
+	collectorLastSuccessGauge.WithLabelValues(c.name).Set(float64(start.Unix()))
+	return nil
+}
+
+// wait - sleep until the next refresh or until triggered.
+// Returns the channel to close once the triggered collection is done.
+func (c *collector) wait() chan struct{} {
+	select {
+	case <-time.After(c.refresh()):
+		return nil
+	case done := <-c.trigger:
+		return done
+	}
+}
+
+// loop - collect forever, every refresh
+func (c *collector) loop(done chan struct{}) {
+	for {
+		c.run()
+		if done != nil {
+			close(done)
+		}
+		done = c.wait()
+	}
+}
+
+// collectNow - trigger a collection of a looping collector and wait for it to be done
+func (c *collector) collectNow() {
+	done := make(chan struct{})
+	c.trigger <- done
+	<-done
+}
+
+// startCollectors - fetch the repositories, then start a goroutine for each enabled collector
+func startCollectors() {
+	repositoriesCollector := collectors[0]
+	repositoriesCollector.run()
+
+	for _, c := range collectors[1:] {
+		if !c.enabled() {
+			slog.Info("skipping collector, as it is not enabled", "collector", c.name)
+			continue
+		}
+		go c.loop(nil)
+	}
+
+	repositoriesCollector.loop(repositoriesCollector.wait())
+}
+
+// InitCollect - register metrics and create the Github client, without starting the collectors
+func InitCollect() error {
+	registerMetrics()
+	return initClient()
+}
+
+// CollectOnce - run each enabled collector a single time, in order.
+// Returns an error if any collector failed during this collection.
+func CollectOnce() error {
+	var failed []string
+	for _, c := range collectors {
+		if !c.enabled() {
+			continue
+		}
+		if err := c.run(); err != nil {
+			failed = append(failed, c.name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("collectors failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var apiNotModifiedCounter = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "github_exporter_api_not_modified_total",
+		Help: "Number of Github API requests answered with 304 Not Modified from the HTTP cache, not charged to the rate limit",
+	},
+)
+
+// notModifiedTransport - count the 304 Not Modified responses, below the HTTP cache that
+// turns them into the cached response
+type notModifiedTransport struct {
+	next http.RoundTripper
+}
+
+func (t notModifiedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusNotModified {
+		apiNotModifiedCounter.Inc()
+	}
+	return resp, err
+}
+
+// diskCache - httpcache.Cache keeping responses as files in a directory, so that ETags survive
+// restarts. The least recently used files are removed once the directory is over maxSize bytes.
+type diskCache struct {
+	dir     string
+	maxSize int64
+
+	mu    sync.Mutex
+	size  int64
+	files map[string]*list.Element
+	// lru - *diskCacheFile, most recently used first
+	lru *list.List
+}
+
+type diskCacheFile struct {
+	name string
+	size int64
+}
+
+// diskCacheTmpPrefix - prefix of the files being written, ignored and removed on load
+const diskCacheTmpPrefix = ".tmp-"
+
+// newDiskCache - open the cache directory, creating it if needed, and index the files already there
+func newDiskCache(dir string, maxSize int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), diskCacheTmpPrefix) {
+			os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+	// Most recently used first, Get touches the files it reads
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+
+	c := &diskCache{dir: dir, maxSize: maxSize, files: map[string]*list.Element{}, lru: list.New()}
+	for _, info := range files {
+		c.files[info.Name()] = c.lru.PushBack(&diskCacheFile{name: info.Name(), size: info.Size()})
+		c.size += info.Size()
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	slog.Info("Github HTTP cache on disk", "dir", dir, "files", len(c.files), "size", c.size)
+	return c, nil
+}
+
+func diskCacheFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get - implements httpcache.Cache
+func (c *diskCache) Get(key string) ([]byte, bool) {
+	name := diskCacheFileName(key)
+	c.mu.Lock()
+	element, ok := c.files[name]
+	if ok {
+		c.lru.MoveToFront(element)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := filepath.Join(c.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.Delete(key)
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Set - implements httpcache.Cache, the file is written then renamed so that a crash never
+// leaves a truncated response behind
+func (c *diskCache) Set(key string, data []byte) {
+	if int64(len(data)) > c.maxSize {
+		return
+	}
+	name := diskCacheFileName(key)
+	tmp, err := os.CreateTemp(c.dir, diskCacheTmpPrefix)
+	if err != nil {
+		slog.Error("writing Github HTTP cache failed", "error", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		slog.Error("writing Github HTTP cache failed", "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.files[name]; ok {
+		file := element.Value.(*diskCacheFile)
+		c.size += int64(len(data)) - file.size
+		file.size = int64(len(data))
+		c.lru.MoveToFront(element)
+	} else {
+		c.files[name] = c.lru.PushFront(&diskCacheFile{name: name, size: int64(len(data))})
+		c.size += int64(len(data))
+	}
+	c.evict()
+}
+
+// Delete - implements httpcache.Cache
+func (c *diskCache) Delete(key string) {
+	name := diskCacheFileName(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.files[name]; ok {
+		c.remove(element)
+	}
+}
+
+// evict - remove the least recently used files until the cache fits in maxSize, mu must be held
+func (c *diskCache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove - remove a file from the index and the disk, mu must be held
+func (c *diskCache) remove(element *list.Element) {
+	file := c.lru.Remove(element).(*diskCacheFile)
+	delete(c.files, file.name)
+	c.size -= file.size
+	os.Remove(filepath.Join(c.dir, file.name))
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// cachedKeys - the keys still in the cache, in order
+func cachedKeys(c *diskCache, keys ...string) []string {
+	var result []string
+	for _, key := range keys {
+		if _, ok := c.Get(key); ok {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// cacheFiles - files of the cache directory
+func cacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDiskCache(t *testing.T) {
+	value := func(size int) []byte { return bytes.Repeat([]byte("x"), size) }
+
+	for _, tc := range []struct {
+		name    string
+		maxSize int64
+		// run - operations on the cache, in order
+		run  func(c *diskCache)
+		want []string
+		size int64
+	}{
+		{
+			name:    "set then get",
+			maxSize: 100,
+			run: func(c *diskCache) {
+				c.Set("a", value(10))
+				c.Set("b", value(20))
+			},
+			want: []string{"a", "b"},
+			size: 30,
+		},
+		{
+			name:    "over the size limit, not stored",
+			maxSize: 100,
+			run: func(c *diskCache) {
+				c.Set("a", value(10))
+				c.Set("big", value(101))
+			},
+			want: []string{"a"},
+			size: 10,
+		},
+		{
+			name:    "least recently used evicted first",
+			maxSize: 30,
+			run: func(c *diskCache) {
+				c.Set("a", value(10))
+				c.Set("b", value(10))
+				c.Set("c", value(10))
+				c.Get("a")
+				c.Set("d", value(10))
+			},
+			want: []string{"a", "c", "d"},
+			size: 30,
+		},
+		{
+			name:    "replaced value resized",
+			maxSize: 30,
+			run: func(c *diskCache) {
+				c.Set("a", value(10))
+				c.Set("b", value(10))
+				c.Set("a", value(20))
+			},
+			want: []string{"a", "b"},
+			size: 30,
+		},
+		{
+			name:    "deleted",
+			maxSize: 100,
+			run: func(c *diskCache) {
+				c.Set("a", value(10))
+				c.Set("b", value(10))
+				c.Delete("a")
+			},
+			want: []string{"b"},
+			size: 10,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c, err := newDiskCache(dir, tc.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.run(c)
+
+			if got := cachedKeys(c, "a", "b", "c", "d", "big"); !slices.Equal(got, tc.want) {
+				t.Errorf("cached %v, want %v", got, tc.want)
+			}
+			if c.size != tc.size {
+				t.Errorf("size = %d, want %d", c.size, tc.size)
+			}
+			if files := cacheFiles(t, dir); len(files) != len(tc.want) {
+				t.Errorf("files %v, want %d", files, len(tc.want))
+			}
+		})
+	}
+}
+
+func TestDiskCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newDiskCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("old", []byte("old response"))
+	c.Set("new", []byte("new response"))
+	// Last used a while ago, evicted first on reload
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, diskCacheFileName("old")), old, old); err != nil {
+		t.Fatal(err)
+	}
+	// Left behind by a crash while writing
+	if err := os.WriteFile(filepath.Join(dir, diskCacheTmpPrefix+"123"), []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := newDiskCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := reloaded.Get("new"); !ok || string(data) != "new response" {
+		t.Errorf("reloaded new = %q %v, want the response set before the restart", data, ok)
+	}
+	if got := cachedKeys(reloaded, "old", "new"); len(got) != 2 {
+		t.Errorf("reloaded %v, want old and new", got)
+	}
+	files := cacheFiles(t, dir)
+	if len(files) != 2 {
+		t.Errorf("files %v, want the temp file removed", files)
+	}
+
+	// Reloaded with a smaller size, the least recently used file is evicted
+	if err := os.Chtimes(filepath.Join(dir, diskCacheFileName("old")), old, old); err != nil {
+		t.Fatal(err)
+	}
+	smaller, err := newDiskCache(dir, int64(len("new response")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedKeys(smaller, "old", "new"); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("reloaded with a smaller size %v, want [new]", got)
+	}
+}
+
+func TestNotModifiedTransport(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		err    error
+		want   float64
+	}{
+		{"304 counted", http.StatusNotModified, nil, 1},
+		{"200 not counted", http.StatusOK, nil, 0},
+		{"error not counted", 0, errors.New("connection refused"), 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := notModifiedTransport{next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if tc.err != nil {
+					return nil, tc.err
+				}
+				return &http.Response{StatusCode: tc.status, Body: http.NoBody}, nil
+			})}
+			before := testutil.ToFloat64(apiNotModifiedCounter)
+			req, _ := http.NewRequest("GET", "http://localhost/", nil)
+			if _, err := transport.RoundTrip(req); err != tc.err {
+				t.Errorf("RoundTrip error = %v, want %v", err, tc.err)
+			}
+			if got := testutil.ToFloat64(apiNotModifiedCounter) - before; got != tc.want {
+				t.Errorf("counted %v not modified responses, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	artifactCountGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_artifact_count",
+			Help: "Number of workflow artifacts stored for a repository",
+		},
+		[]string{"repo"},
+	)
+	artifactSizeGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_artifact_size_bytes",
+			Help: "Total size (in bytes) of the active workflow artifacts of a repository, expired artifacts use no storage",
+		},
+		[]string{"repo"},
+	)
+	artifactStatusGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_artifact_status_count",
+			Help: "Number of workflow artifacts for a repository by status (active/expired)",
+		},
+		[]string{"repo", "status"},
+	)
+	artifactOldestAgeGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_artifact_oldest_age_seconds",
+			Help: "Age (in seconds) of the oldest active workflow artifact of a repository",
+		},
+		[]string{"repo"},
+	)
+)
+
+func getAllRepoArtifacts(ctx context.Context, owner string, repo string) ([]*github.Artifact, error) {
+	var artifacts []*github.Artifact
+	opt := &github.ListOptions{PerPage: 100}
+
+	for {
+		resp, rr, err := client.Actions.ListArtifacts(ctx, owner, repo, opt)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "ListArtifacts", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "ListArtifacts", err, "org", owner, "repo", repo)
+			return nil, err
+		}
+
+		artifacts = append(artifacts, resp.Artifacts...)
+		if rr.NextPage == 0 {
+			break
+		}
+		opt.Page = rr.NextPage
+	}
+
+	return artifacts, nil
+}
+
+// getArtifactsFromGithub - return artifact storage informations for each repo.
+// A repo whose calls failed keeps the series of the previous refresh.
+func getArtifactsFromGithub(ctx context.Context) error {
+	artifactCountGauge.Reset()
+	artifactSizeGauge.Reset()
+	artifactStatusGauge.Reset()
+	artifactOldestAgeGauge.Reset()
+
+	var errs []error
+	for _, repo := range Repositories() {
+		r := strings.Split(repo, "/")
+
+		artifacts, err := getAllRepoArtifacts(ctx, r[0], r[1])
+		if err != nil {
+			errs = append(errs, err)
+			labels := prometheus.Labels{"repo": repo}
+			artifactCountGauge.carryOver(labels)
+			artifactSizeGauge.carryOver(labels)
+			artifactStatusGauge.carryOver(labels)
+			artifactOldestAgeGauge.carryOver(labels)
+			continue
+		}
+		var size, active, expired int64
+		var oldest time.Time
+		for _, artifact := range artifacts {
+			if artifact.GetExpired() {
+				expired++
+				continue
+			}
+			active++
+			size += artifact.GetSizeInBytes()
+			created := artifact.GetCreatedAt().Time
+			if oldest.IsZero() || created.Before(oldest) {
+				oldest = created
+			}
+		}
+
+		artifactCountGauge.WithLabelValues(repo).Set(float64(len(artifacts)))
+		artifactSizeGauge.WithLabelValues(repo).Set(float64(size))
+		artifactStatusGauge.WithLabelValues(repo, "active").Set(float64(active))
+		artifactStatusGauge.WithLabelValues(repo, "expired").Set(float64(expired))
+		if !oldest.IsZero() {
+			artifactOldestAgeGauge.WithLabelValues(repo).Set(time.Since(oldest).Seconds())
+		}
+	}
+	return partial(errs)
+}
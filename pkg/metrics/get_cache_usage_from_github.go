@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheUsageCountGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_cache_active_count",
+			Help: "Number of active Actions caches for a repository",
+		},
+		[]string{"repo"},
+	)
+	cacheUsageSizeGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_cache_active_size_bytes",
+			Help: "Total size (in bytes) of active Actions caches for a repository",
+		},
+		[]string{"repo"},
+	)
+	cacheUsageOrganizationCountGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_cache_organization_active_count",
+			Help: "Number of active Actions caches across all repositories of an organization",
+		},
+		[]string{"organization"},
+	)
+	cacheUsageOrganizationSizeGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_actions_cache_organization_active_size_bytes",
+			Help: "Total size (in bytes) of active Actions caches across all repositories of an organization",
+		},
+		[]string{"organization"},
+	)
+)
+
+// repoCacheUsage - response of /repos/{owner}/{repo}/actions/cache/usage.
+// Not available in go-github v45, so the endpoint is called directly.
+type repoCacheUsage struct {
+	FullName                string `json:"full_name"`
+	ActiveCachesSizeInBytes int64  `json:"active_caches_size_in_bytes"`
+	ActiveCachesCount       int64  `json:"active_caches_count"`
+}
+
+// orgCacheUsage - response of /orgs/{org}/actions/cache/usage
+type orgCacheUsage struct {
+	TotalActiveCachesSizeInBytes int64 `json:"total_active_caches_size_in_bytes"`
+	TotalActiveCachesCount       int64 `json:"total_active_caches_count"`
+}
+
+// getCacheUsage - GET the given endpoint with the shared client and decode the response into v
+func getCacheUsage(ctx context.Context, endpoint string, v interface{}) error {
+	for {
+		req, err := client.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return err
+		}
+		_, err = client.Do(ctx, req, v)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "GetCacheUsage", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		}
+		return err
+	}
+}
+
+// getCacheUsageFromGithub - return Actions cache usage for each repo and organization.
+// A repo or organization whose call failed keeps the series of the previous refresh.
+func getCacheUsageFromGithub(ctx context.Context) error {
+	cacheUsageCountGauge.Reset()
+	cacheUsageSizeGauge.Reset()
+	cacheUsageOrganizationCountGauge.Reset()
+	cacheUsageOrganizationSizeGauge.Reset()
+
+	var errs []error
+	for _, repo := range Repositories() {
+		r := strings.Split(repo, "/")
+
+		usage := &repoCacheUsage{}
+		if err := getCacheUsage(ctx, fmt.Sprintf("repos/%v/%v/actions/cache/usage", r[0], r[1]), usage); err != nil {
+			logAPIError(ctx, "GetCacheUsage", err, "org", r[0], "repo", r[1])
+			errs = append(errs, err)
+			cacheUsageCountGauge.carryOver(prometheus.Labels{"repo": repo})
+			cacheUsageSizeGauge.carryOver(prometheus.Labels{"repo": repo})
+			continue
+		}
+		cacheUsageCountGauge.WithLabelValues(repo).Set(float64(usage.ActiveCachesCount))
+		cacheUsageSizeGauge.WithLabelValues(repo).Set(float64(usage.ActiveCachesSizeInBytes))
+	}
+
+	for _, orga := range config.Organizations() {
+		usage := &orgCacheUsage{}
+		if err := getCacheUsage(ctx, fmt.Sprintf("orgs/%v/actions/cache/usage", orga), usage); err != nil {
+			logAPIError(ctx, "GetCacheUsage", err, "org", orga)
+			errs = append(errs, err)
+			cacheUsageOrganizationCountGauge.carryOver(prometheus.Labels{"organization": orga})
+			cacheUsageOrganizationSizeGauge.carryOver(prometheus.Labels{"organization": orga})
+			continue
+		}
+		cacheUsageOrganizationCountGauge.WithLabelValues(orga).Set(float64(usage.TotalActiveCachesCount))
+		cacheUsageOrganizationSizeGauge.WithLabelValues(orga).Set(float64(usage.TotalActiveCachesSizeInBytes))
+	}
+	return partial(errs)
+}
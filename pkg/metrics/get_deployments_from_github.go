@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deploymentStatusGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_deployment_status_count",
+			Help: "Number of deployments created in the last 1hr by environment and latest state",
+		},
+		[]string{"repo", "environment", "state"},
+	)
+	deploymentApprovalWaitGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_deployment_approval_wait_seconds",
+			Help: "Time (in seconds) a workflow run waited for an environment approval, for deployments created in the last 1hr. One series per run that waited, deployments not created by a workflow run are skipped",
+		},
+		[]string{"repo", "environment", "run_id"},
+	)
+	deploymentPendingGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_deployment_pending_count",
+			Help: "Number of workflow runs currently waiting for an environment approval",
+		},
+		[]string{"repo", "environment"},
+	)
+	deploymentPendingWaitGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_deployment_pending_wait_seconds",
+			Help: "Time (in seconds) the oldest pending deployment of an environment has been waiting for approval",
+		},
+		[]string{"repo", "environment"},
+	)
+
+	// runIDFromURL - extract the run id from a deployment status log_url / target_url
+	runIDFromURL = regexp.MustCompile(`/actions/runs/(\d+)`)
+)
+
+// pendingDeployment - response item of /repos/{owner}/{repo}/actions/runs/{run_id}/pending_deployments.
+// Not available in go-github v45, so the endpoint is called directly.
+type pendingDeployment struct {
+	Environment struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"environment"`
+	WaitTimer          int64             `json:"wait_timer"`
+	WaitTimerStartedAt *github.Timestamp `json:"wait_timer_started_at"`
+}
+
+func getRecentDeployments(ctx context.Context, owner string, repo string) ([]*github.Deployment, error) {
+	window_start := time.Now().Add(time.Duration(-1) * time.Hour)
+	opt := &github.DeploymentsListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var deployments []*github.Deployment
+	for {
+		resp, rr, err := client.Repositories.ListDeployments(ctx, owner, repo, opt)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "ListDeployments", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "ListDeployments", err, "org", owner, "repo", repo)
+			return deployments, err
+		}
+
+		// Deployments are returned newest first, stop paging once we leave the window
+		for _, deployment := range resp {
+			if deployment.GetCreatedAt().Time.Before(window_start) {
+				return deployments, nil
+			}
+			deployments = append(deployments, deployment)
+		}
+		if rr.NextPage == 0 {
+			break
+		}
+		opt.Page = rr.NextPage
+	}
+
+	return deployments, nil
+}
+
+func getDeploymentStatuses(ctx context.Context, owner string, repo string, deploymentId int64) ([]*github.DeploymentStatus, error) {
+	opt := &github.ListOptions{PerPage: 100}
+
+	var statuses []*github.DeploymentStatus
+	for {
+		resp, rr, err := client.Repositories.ListDeploymentStatuses(ctx, owner, repo, deploymentId, opt)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "ListDeploymentStatuses", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "ListDeploymentStatuses", err, "org", owner, "repo", repo)
+			return statuses, err
+		}
+
+		statuses = append(statuses, resp...)
+		if rr.NextPage == 0 {
+			break
+		}
+		opt.Page = rr.NextPage
+	}
+
+	return statuses, nil
+}
+
+func getPendingDeployments(ctx context.Context, owner string, repo string, runId int64) ([]*pendingDeployment, error) {
+	for {
+		req, err := client.NewRequest("GET", fmt.Sprintf("repos/%v/%v/actions/runs/%v/pending_deployments", owner, repo, runId), nil)
+		if err != nil {
+			logAPIError(ctx, "GetPendingDeployments", err, "org", owner, "repo", repo, "run_id", runId)
+			return nil, err
+		}
+		var pending []*pendingDeployment
+		_, err = client.Do(ctx, req, &pending)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "GetPendingDeployments", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "GetPendingDeployments", err, "org", owner, "repo", repo, "run_id", runId)
+			return nil, err
+		}
+		return pending, nil
+	}
+}
+
+// getApprovalWait - sum the time a deployment spent in the "waiting" state.
+// Statuses are returned newest first, a "waiting" status ends with the next status.
+func getApprovalWait(statuses []*github.DeploymentStatus) time.Duration {
+	sorted := make([]*github.DeploymentStatus, len(statuses))
+	copy(sorted, statuses)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetCreatedAt().Time.Before(sorted[j].GetCreatedAt().Time)
+	})
+
+	var wait time.Duration
+	for i, status := range sorted {
+		if status.GetState() != "waiting" {
+			continue
+		}
+		end := time.Now()
+		if i+1 < len(sorted) {
+			end = sorted[i+1].GetCreatedAt().Time
+		}
+		wait += end.Sub(status.GetCreatedAt().Time)
+	}
+	return wait
+}
+
+// getDeploymentRunID - return the workflow run id a deployment belongs to, from its statuses urls.
+// Empty for a deployment not created by a workflow run.
+func getDeploymentRunID(statuses []*github.DeploymentStatus) string {
+	for _, status := range statuses {
+		for _, u := range []string{status.GetLogURL(), status.GetTargetURL()} {
+			if m := runIDFromURL.FindStringSubmatch(u); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// getDeploymentsFromGithub - return deployments and environment approval informations for each repo.
+// A repo whose calls failed keeps the series of the previous refresh.
+func getDeploymentsFromGithub(ctx context.Context) error {
+	deploymentStatusGauge.Reset()
+	deploymentApprovalWaitGauge.Reset()
+	deploymentPendingGauge.Reset()
+	deploymentPendingWaitGauge.Reset()
+
+	var errs []error
+	for _, repo := range Repositories() {
+		r := strings.Split(repo, "/")
+		repoErrs := len(errs)
+
+		deployments, err := getRecentDeployments(ctx, r[0], r[1])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, deployment := range deployments {
+			statuses, err := getDeploymentStatuses(ctx, r[0], r[1], deployment.GetID())
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if len(statuses) == 0 {
+				continue
+			}
+			environment := deployment.GetEnvironment()
+			deploymentStatusGauge.WithLabelValues(repo, environment, statuses[0].GetState()).Inc()
+
+			wait := getApprovalWait(statuses)
+			if runID := getDeploymentRunID(statuses); wait > 0 && runID != "" {
+				deploymentApprovalWaitGauge.WithLabelValues(repo, environment, runID).Add(wait.Seconds())
+			}
+		}
+
+		oldestPending := map[string]time.Time{}
+		waiting, err := getWorkflowRunsByStatus(ctx, r[0], r[1], "waiting")
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, run := range waiting {
+			pendings, err := getPendingDeployments(ctx, r[0], r[1], run.GetID())
+			if err != nil {
+				errs = append(errs, err)
+			}
+			for _, pending := range pendings {
+				started := run.GetUpdatedAt().Time
+				if pending.WaitTimerStartedAt != nil {
+					started = pending.WaitTimerStartedAt.Time
+				}
+				deploymentPendingGauge.WithLabelValues(repo, pending.Environment.Name).Inc()
+				if oldest, ok := oldestPending[pending.Environment.Name]; !ok || started.Before(oldest) {
+					oldestPending[pending.Environment.Name] = started
+				}
+			}
+		}
+		for environment, started := range oldestPending {
+			deploymentPendingWaitGauge.WithLabelValues(repo, environment).Set(time.Since(started).Seconds())
+		}
+
+		if len(errs) > repoErrs {
+			labels := prometheus.Labels{"repo": repo}
+			deploymentStatusGauge.carryOver(labels)
+			deploymentApprovalWaitGauge.carryOver(labels)
+			deploymentPendingGauge.carryOver(labels)
+			deploymentPendingWaitGauge.carryOver(labels)
+		}
+	}
+	return partial(errs)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDeploymentApprovalWait(t *testing.T) {
+	created := time.Now().Add(-30 * time.Minute)
+	at := func(minutes int) string {
+		return created.Add(time.Duration(minutes) * time.Minute).UTC().Format(time.RFC3339)
+	}
+	fakeGithub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/foo/a/deployments":
+			fmt.Fprintf(w, `[
+				{"id": 1, "environment": "production", "created_at": %q},
+				{"id": 2, "environment": "production", "created_at": %q},
+				{"id": 3, "environment": "staging", "created_at": %q}
+			]`, at(0), at(0), at(0))
+		case "/repos/foo/a/deployments/1/statuses":
+			// Newest first, waited 5 minutes
+			fmt.Fprintf(w, `[
+				{"state": "success", "created_at": %q, "log_url": "https://github.com/foo/a/actions/runs/42/job/7"},
+				{"state": "waiting", "created_at": %q, "log_url": "https://github.com/foo/a/actions/runs/42/job/7"}
+			]`, at(5), at(0))
+		case "/repos/foo/a/deployments/2/statuses", "/repos/foo/a/deployments/3/statuses":
+			// Not created by a workflow run
+			fmt.Fprintf(w, `[
+				{"state": "success", "created_at": %q, "target_url": "https://deploy.example.com/1"},
+				{"state": "waiting", "created_at": %q}
+			]`, at(2), at(0))
+		case "/repos/foo/a/actions/runs":
+			fmt.Fprint(w, `{"total_count": 0, "workflow_runs": []}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	setRepositories([]string{"foo/a"})
+	t.Cleanup(func() { setRepositories(nil) })
+
+	if err := getDeploymentsFromGithub(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deploymentApprovalWaitGauge.publish()
+	deploymentStatusGauge.publish()
+
+	got := served(t, deploymentApprovalWaitGauge)
+	if len(got) != 1 || got["production,foo/a,42"] != 300 {
+		t.Errorf("github_deployment_approval_wait_seconds = %v, want only run 42 with 300s", got)
+	}
+	if got := served(t, deploymentStatusGauge); got["production,foo/a,success"] != 2 || got["staging,foo/a,success"] != 1 {
+		t.Errorf("github_deployment_status_count = %v, want every deployment", got)
+	}
+}
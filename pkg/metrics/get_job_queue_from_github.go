@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobQueueGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_job_queue_count",
+			Help: "Number of jobs of not yet completed workflow runs by status (queued/waiting/pending/requested/in_progress), runner label set and runner group",
+		},
+		[]string{"org", "status", "runner_labels", "runner_group"},
+	)
+	jobQueueOldestAgeGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_job_queue_oldest_age_seconds",
+			Help: "Age (in seconds) of the oldest job waiting for a runner (queued/waiting/pending/requested) by runner label set and runner group",
+		},
+		[]string{"org", "runner_labels", "runner_group"},
+	)
+
+	// activeJobs - waiting and in progress jobs seen by the last job queue refresh
+	activeJobs struct {
+		sync.RWMutex
+		jobs []activeJob
+	}
+)
+
+// IsWaitingJobStatus - true for the statuses of a job that is not picked by a runner yet: queued,
+// waiting (for an environment approval or a concurrency group), pending and requested
+func IsWaitingJobStatus(status string) bool {
+	switch status {
+	case "queued", "waiting", "pending", "requested":
+		return true
+	}
+	return false
+}
+
+// activeJob - a waiting or in progress job of a not yet completed workflow run
+type activeJob struct {
+	Org         string
+	Repo        string
+	Run         *github.WorkflowRun
+	Job         *workflowJob
+	Labels      string
+	RunnerGroup string
+}
+
+func newActiveJob(repo string, run *github.WorkflowRun, job *workflowJob) activeJob {
+	return activeJob{
+		Org:         strings.Split(repo, "/")[0],
+		Repo:        repo,
+		Run:         run,
+		Job:         job,
+		Labels:      normaliseRunnerLabels(job.Labels),
+		RunnerGroup: job.GetRunnerGroupName(),
+	}
+}
+
+// normaliseRunnerLabels - lower case, deduplicate and sort runs-on labels so that
+// the same label set always gives the same label string
+func normaliseRunnerLabels(labels []string) string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.ToLower(strings.TrimSpace(label))
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		result = append(result, label)
+	}
+	sort.Strings(result)
+	return getRunnerLabelString(result)
+}
+
+// activeRuns - not yet completed runs from the last workflow runs refresh, one per run id
+func activeRuns() []observedRun {
+	runState.RLock()
+	defer runState.RUnlock()
+	seen := map[int64]bool{}
+	var result []observedRun
+	for _, o := range runState.runs {
+		if o.Run.GetStatus() == "completed" || seen[o.Run.GetID()] {
+			continue
+		}
+		seen[o.Run.GetID()] = true
+		result = append(result, o)
+	}
+	return result
+}
+
+// getJobQueueFromGithub - return waiting and in progress jobs of not yet completed runs.
+// The runs are the ones followed by getWorkflowRunsFromGithub, only their jobs are fetched,
+// so this can be refreshed faster. A run whose jobs could not be fetched keeps its jobs
+// from the previous refresh.
+func getJobQueueFromGithub(ctx context.Context) error {
+	previous := map[int64][]activeJob{}
+	activeJobs.RLock()
+	for _, j := range activeJobs.jobs {
+		previous[j.Run.GetID()] = append(previous[j.Run.GetID()], j)
+	}
+	activeJobs.RUnlock()
+
+	var jobs []activeJob
+	var errs []error
+	for _, o := range activeRuns() {
+		r := strings.Split(o.Repo, "/")
+		runJobs, err := getWorkflowJobs(ctx, r[0], r[1], o.Run.GetID())
+		if err != nil {
+			errs = append(errs, err)
+			jobs = append(jobs, previous[o.Run.GetID()]...)
+			continue
+		}
+		for _, job := range runJobs {
+			if !IsWaitingJobStatus(job.GetStatus()) && job.GetStatus() != "in_progress" {
+				continue
+			}
+			jobs = append(jobs, newActiveJob(o.Repo, o.Run, job))
+		}
+	}
+
+	activeJobs.Lock()
+	activeJobs.jobs = jobs
+	activeJobs.Unlock()
+
+	jobQueueGauge.Reset()
+	jobQueueOldestAgeGauge.Reset()
+	oldestQueued := map[[3]string]time.Time{}
+	for _, j := range jobs {
+		jobQueueGauge.WithLabelValues(j.Org, j.Job.GetStatus(), j.Labels, j.RunnerGroup).Inc()
+		if !IsWaitingJobStatus(j.Job.GetStatus()) {
+			continue
+		}
+		key := [3]string{j.Org, j.Labels, j.RunnerGroup}
+		if oldest, ok := oldestQueued[key]; !ok || j.Job.GetCreatedAt().Time.Before(oldest) {
+			oldestQueued[key] = j.Job.GetCreatedAt().Time
+		}
+	}
+	for key, oldest := range oldestQueued {
+		jobQueueOldestAgeGauge.WithLabelValues(key[0], key[1], key[2]).Set(time.Since(oldest).Seconds())
+	}
+	return partial(errs)
+}
+
+// JobQueueFilter - select active jobs by organization, runs-on labels and runner group.
+// Empty fields match every job.
+type JobQueueFilter struct {
+	Org         string
+	Labels      []string
+	RunnerGroup string
+}
+
+// CountActiveJobs - return the number of waiting (see IsWaitingJobStatus) and in progress jobs
+// matching the filter, from the last job queue refresh. Waiting jobs are not assigned to a
+// runner group yet, so they match any runner group.
+func CountActiveJobs(filter JobQueueFilter) (queued int, inProgress int) {
+	labels := normaliseRunnerLabels(filter.Labels)
+
+	activeJobs.RLock()
+	defer activeJobs.RUnlock()
+	for _, j := range activeJobs.jobs {
+		if filter.Org != "" && !strings.EqualFold(filter.Org, j.Org) {
+			continue
+		}
+		if labels != "" && labels != j.Labels {
+			continue
+		}
+		if filter.RunnerGroup != "" && j.RunnerGroup != "" && filter.RunnerGroup != j.RunnerGroup {
+			continue
+		}
+		if IsWaitingJobStatus(j.Job.GetStatus()) {
+			queued++
+		} else {
+			inProgress++
+		}
+	}
+	return queued, inProgress
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// fakeGithub - point the shared client to handler for the duration of the test
+func fakeGithub(t *testing.T, handler http.Handler) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	previous := client
+	client = github.NewClient(server.Client())
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	t.Cleanup(func() {
+		client = previous
+		server.Close()
+	})
+}
+
+func TestGetJobQueueFromGithub(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	fakeGithub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/repos/foo/a/actions/runs/1/jobs":
+			fmt.Fprint(w, `{"total_count": 3, "jobs": [
+				{"id": 11, "run_id": 1, "status": "queued", "labels": ["self-hosted", "Linux"]},
+				{"id": 12, "run_id": 1, "status": "in_progress", "labels": ["linux", "self-hosted"], "runner_group_name": "default"},
+				{"id": 13, "run_id": 1, "status": "completed", "labels": ["linux"]}
+			]}`)
+		case "/repos/foo/b/actions/runs/3/jobs":
+			http.Error(w, `{"message": "server error"}`, http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	run := func(id int64, status string) *github.WorkflowRun {
+		return &github.WorkflowRun{ID: github.Int64(id), Status: github.String(status)}
+	}
+	setObservedRuns([]observedRun{
+		{Repo: "foo/a", Run: run(1, "in_progress")},
+		{Repo: "foo/a", Run: run(1, "in_progress")},
+		{Repo: "foo/a", Run: run(2, "completed")},
+		{Repo: "foo/b", Run: run(3, "queued")},
+	})
+	// Jobs of the previous refresh, kept for the run whose jobs can't be fetched
+	activeJobs.Lock()
+	activeJobs.jobs = []activeJob{
+		newActiveJob("foo/b", run(3, "queued"), &workflowJob{WorkflowJob: &github.WorkflowJob{ID: github.Int64(31), RunID: github.Int64(3), Status: github.String("queued")}}),
+		newActiveJob("foo/c", run(4, "queued"), &workflowJob{WorkflowJob: &github.WorkflowJob{ID: github.Int64(41), RunID: github.Int64(4), Status: github.String("queued")}}),
+	}
+	activeJobs.Unlock()
+	t.Cleanup(func() {
+		setObservedRuns(nil)
+		activeJobs.Lock()
+		activeJobs.jobs = nil
+		activeJobs.Unlock()
+	})
+
+	err := getJobQueueFromGithub(context.Background())
+	if !errors.As(err, &partialError{}) {
+		t.Errorf("error = %v, want a partial error", err)
+	}
+	if len(calls) != 2 || calls["/repos/foo/a/actions/runs/1/jobs"] != 1 || calls["/repos/foo/b/actions/runs/3/jobs"] != 1 {
+		t.Errorf("calls = %v, want the jobs of runs 1 and 3 once each", calls)
+	}
+
+	for _, tc := range []struct {
+		filter     JobQueueFilter
+		queued     int
+		inProgress int
+	}{
+		{JobQueueFilter{}, 2, 1},
+		{JobQueueFilter{Labels: []string{"linux", "SELF-HOSTED"}}, 1, 1},
+		{JobQueueFilter{RunnerGroup: "other"}, 2, 0},
+		{JobQueueFilter{Org: "bar"}, 0, 0},
+	} {
+		queued, inProgress := CountActiveJobs(tc.filter)
+		if queued != tc.queued || inProgress != tc.inProgress {
+			t.Errorf("CountActiveJobs(%+v) = %d, %d, want %d, %d", tc.filter, queued, inProgress, tc.queued, tc.inProgress)
+		}
+	}
+}
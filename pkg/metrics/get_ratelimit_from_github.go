@@ -2,15 +2,12 @@ package metrics
 
 import (
 	"context"
-	"log"
-	"time"
 
-	"github.com/chipgata/github-actions-exporter/pkg/config"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	rateLimitGauge = prometheus.NewGaugeVec(
+	rateLimitGauge = newSnapshotGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "github_ralimit_remaining_by_hour",
 			Help: "Number of billable seconds used by a specific workflow during the current billing cycle. Any job re-runs are also included in the usage. Only apply to workflows in private repositories that use GitHub-hosted runners.",
@@ -20,16 +17,15 @@ var (
 )
 
 // getRateLimitFromGithub - return ratelimit informations.
-func getRateLimitFromGithub() {
-	for {
-		rateLimitGauge.Reset()
+func getRateLimitFromGithub(ctx context.Context) error {
+	rateLimitGauge.Reset()
 
-		resp, _, err := client.RateLimits(context.Background())
-		if err != nil {
-			log.Printf("getRateLimitFromGithub error: %s", err.Error())
-			continue
-		}
-		rateLimitGauge.WithLabelValues().Set(float64(resp.Core.Remaining))
-		time.Sleep(time.Duration(config.Github.Refresh) * time.Second)
+	resp, _, err := client.RateLimits(ctx)
+	if err != nil {
+		logAPIError(ctx, "RateLimits", err)
+		return err
 	}
+	rateLimitGauge.WithLabelValues().Set(float64(resp.Core.Remaining))
+	setRateLimit(*resp.Core)
+	return nil
 }
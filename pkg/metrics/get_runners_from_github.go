@@ -2,19 +2,16 @@ package metrics
 
 import (
 	"context"
-	"log"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/chipgata/github-actions-exporter/pkg/config"
-
 	"github.com/google/go-github/v45/github"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	runnersGauge = prometheus.NewGaugeVec(
+	runnersGauge = newSnapshotGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "github_runner_status",
 			Help: "runner status",
@@ -23,19 +20,19 @@ var (
 	)
 )
 
-func getAllRepoRunners(owner string, repo string) []*github.Runner {
+func getAllRepoRunners(ctx context.Context, owner string, repo string) ([]*github.Runner, error) {
 	var runners []*github.Runner
 	opt := &github.ListOptions{PerPage: 200}
 
 	for {
-		resp, rr, err := client.Actions.ListRunners(context.Background(), owner, repo, opt)
+		resp, rr, err := client.Actions.ListRunners(ctx, owner, repo, opt)
 		if rl_err, ok := err.(*github.RateLimitError); ok {
-			log.Printf("ListRunners ratelimited. Pausing until %s", rl_err.Rate.Reset.Time.String())
+			logRateLimited(ctx, "ListRunners", rl_err)
 			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
 			continue
 		} else if err != nil {
-			log.Printf("ListRunners error for repo %s: %s", repo, err.Error())
-			return nil
+			logAPIError(ctx, "ListRunners", err, "org", owner, "repo", repo)
+			return nil, err
 		}
 
 		runners = append(runners, resp.Runners...)
@@ -45,27 +42,41 @@ func getAllRepoRunners(owner string, repo string) []*github.Runner {
 		opt.Page = rr.NextPage
 	}
 
-	return runners
+	return runners, nil
 }
 
-// getRunnersFromGithub - return information about runners and their status for a specific repo
-func getRunnersFromGithub() {
-	for {
-		runnersGauge.Reset()
+// getRunnersFromGithub - return information about runners and their status for a specific repo.
+// The runners of a repo that failed are those of the previous refresh.
+func getRunnersFromGithub(ctx context.Context) error {
+	runnersGauge.Reset()
+	runnerState.RLock()
+	previous := runnerState.repo
+	runnerState.RUnlock()
 
-		for _, repo := range repositories {
-			r := strings.Split(repo, "/")
+	var errs []error
+	repoRunners := map[string][]*github.Runner{}
+	for _, repo := range Repositories() {
+		r := strings.Split(repo, "/")
 
-			runners := getAllRepoRunners(r[0], r[1])
-			for _, runner := range runners {
-				if runner.GetStatus() == "online" {
-					runnersGauge.WithLabelValues(repo, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy())).Set(1)
-				} else {
-					runnersGauge.WithLabelValues(repo, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy())).Set(0)
-				}
+		runners, err := getAllRepoRunners(ctx, r[0], r[1])
+		if err != nil {
+			errs = append(errs, err)
+			runnersGauge.carryOver(prometheus.Labels{"repo": repo})
+			if runners, ok := previous[repo]; ok {
+				repoRunners[repo] = runners
+			}
+			continue
+		}
+		repoRunners[repo] = runners
+		for _, runner := range runners {
+			if runner.GetStatus() == "online" {
+				runnersGauge.WithLabelValues(repo, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy())).Set(1)
+			} else {
+				runnersGauge.WithLabelValues(repo, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy())).Set(0)
 			}
 		}
-
-		time.Sleep(time.Duration(config.Github.Refresh) * time.Second)
 	}
+
+	setRepoRunners(repoRunners)
+	return partial(errs)
 }
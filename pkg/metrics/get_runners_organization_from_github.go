@@ -2,7 +2,6 @@ package metrics
 
 import (
 	"context"
-	"log"
 	"strconv"
 	"time"
 
@@ -13,7 +12,7 @@ import (
 )
 
 var (
-	runnersOrganizationGauge = prometheus.NewGaugeVec(
+	runnersOrganizationGauge = newSnapshotGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "github_runner_organization_status",
 			Help: "runner status",
@@ -22,19 +21,19 @@ var (
 	)
 )
 
-func getAllOrgRunners(orga string) []*github.Runner {
+func getAllOrgRunners(ctx context.Context, orga string) ([]*github.Runner, error) {
 	var runners []*github.Runner
 	opt := &github.ListOptions{PerPage: 200}
 
 	for {
-		resp, rr, err := client.Actions.ListOrganizationRunners(context.Background(), orga, opt)
+		resp, rr, err := client.Actions.ListOrganizationRunners(ctx, orga, opt)
 		if rl_err, ok := err.(*github.RateLimitError); ok {
-			log.Printf("ListOrganizationRunners ratelimited. Pausing until %s", rl_err.Rate.Reset.Time.String())
+			logRateLimited(ctx, "ListOrganizationRunners", rl_err)
 			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
 			continue
 		} else if err != nil {
-			log.Printf("ListOrganizationRunners error for org %s: %s", orga, err.Error())
-			return runners
+			logAPIError(ctx, "ListOrganizationRunners", err, "org", orga)
+			return runners, err
 		}
 
 		runners = append(runners, resp.Runners...)
@@ -43,29 +42,111 @@ func getAllOrgRunners(orga string) []*github.Runner {
 		}
 		opt.Page = rr.NextPage
 	}
-	return runners
+	return runners, nil
 }
 
-// getRunnersOrganizationFromGithub - return information about runners and their status for an organization
-func getRunnersOrganizationFromGithub() {
+// getOrgRunnerGroups - return the runner group name of each runner of an organization, by runner id
+func getOrgRunnerGroups(ctx context.Context, orga string) (map[int64]string, error) {
+	groups := map[int64]string{}
+	opt := &github.ListOrgRunnerGroupOptions{ListOptions: github.ListOptions{PerPage: 100}}
+
 	for {
-		for _, orga := range config.Github.Organizations.Value() {
-			runners := getAllOrgRunners(orga)
+		resp, rr, err := client.Actions.ListOrganizationRunnerGroups(ctx, orga, opt)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "ListOrganizationRunnerGroups", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "ListOrganizationRunnerGroups", err, "org", orga)
+			return groups, err
+		}
+
+		for _, group := range resp.RunnerGroups {
+			runners, err := getRunnerGroupRunners(ctx, orga, group.GetID())
+			if err != nil {
+				return groups, err
+			}
 			for _, runner := range runners {
-				runnerLabels := make([]string, 0, len(runner.Labels))
-				for _, label := range runner.Labels {
-					runnerLabels = append(runnerLabels, label.GetName())
-				}
-				runnerLabelString := getRunnerLabelString(runnerLabels)
-				if runner.GetStatus() == "online" {
-					runnersOrganizationGauge.WithLabelValues(orga, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy()), runnerLabelString).Set(1)
-				} else {
-					runnersOrganizationGauge.WithLabelValues(orga, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy()), runnerLabelString).Set(0)
-				}
+				groups[runner.GetID()] = group.GetName()
 			}
 		}
+		if rr.NextPage == 0 {
+			break
+		}
+		opt.Page = rr.NextPage
+	}
+	return groups, nil
+}
+
+func getRunnerGroupRunners(ctx context.Context, orga string, groupId int64) ([]*github.Runner, error) {
+	var runners []*github.Runner
+	opt := &github.ListOptions{PerPage: 100}
 
-		time.Sleep(time.Duration(config.Github.Refresh) * time.Second)
-		runnersOrganizationGauge.Reset()
+	for {
+		resp, rr, err := client.Actions.ListRunnerGroupRunners(ctx, orga, groupId, opt)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "ListRunnerGroupRunners", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "ListRunnerGroupRunners", err, "org", orga, "runner_group_id", groupId)
+			return runners, err
+		}
+
+		runners = append(runners, resp.Runners...)
+		if rr.NextPage == 0 {
+			break
+		}
+		opt.Page = rr.NextPage
+	}
+	return runners, nil
+}
+
+// getRunnersOrganizationFromGithub - return information about runners and their status for an organization.
+// The runners and runner groups of an organization that failed are those of the previous refresh.
+func getRunnersOrganizationFromGithub(ctx context.Context) error {
+	runnersOrganizationGauge.Reset()
+	runnerState.RLock()
+	previousRunners, previousGroups := runnerState.organization, runnerState.groups
+	runnerState.RUnlock()
+
+	var errs []error
+	orgRunners := map[string][]*github.Runner{}
+	orgRunnerGroups := map[string]map[int64]string{}
+	for _, orga := range config.Organizations() {
+		runners, err := getAllOrgRunners(ctx, orga)
+		if err != nil {
+			errs = append(errs, err)
+			runnersOrganizationGauge.carryOver(prometheus.Labels{"organization": orga})
+			if runners, ok := previousRunners[orga]; ok {
+				orgRunners[orga] = runners
+				orgRunnerGroups[orga] = previousGroups[orga]
+			}
+			continue
+		}
+		orgRunners[orga] = runners
+		if len(runners) > 0 {
+			groups, err := getOrgRunnerGroups(ctx, orga)
+			if err != nil {
+				errs = append(errs, err)
+				groups = previousGroups[orga]
+			}
+			orgRunnerGroups[orga] = groups
+		}
+		for _, runner := range runners {
+			runnerLabels := make([]string, 0, len(runner.Labels))
+			for _, label := range runner.Labels {
+				runnerLabels = append(runnerLabels, label.GetName())
+			}
+			runnerLabelString := getRunnerLabelString(runnerLabels)
+			if runner.GetStatus() == "online" {
+				runnersOrganizationGauge.WithLabelValues(orga, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy()), runnerLabelString).Set(1)
+			} else {
+				runnersOrganizationGauge.WithLabelValues(orga, *runner.OS, *runner.Name, strconv.FormatInt(runner.GetID(), 10), strconv.FormatBool(runner.GetBusy()), runnerLabelString).Set(0)
+			}
+		}
 	}
+
+	setOrganizationRunners(orgRunners, orgRunnerGroups)
+	return partial(errs)
 }
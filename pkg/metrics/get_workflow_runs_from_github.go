@@ -2,11 +2,14 @@ package metrics
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/chipgata/github-actions-exporter/pkg/config"
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,21 +18,83 @@ import (
 )
 
 var (
-	workflowJobDurationTotalGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	workflowJobDurationTotalGauge = newSnapshotGaugeVec(prometheus.GaugeOpts{
 		Name: "github_workflow_job_duration_total_ms",
 		Help: "The total duration of jobs.",
 	},
-		[]string{"org", "repo", "branch", "status", "conclusion", "runner_group", "runner_labels", "workflow_name", "job_name", "job_id"},
+		[]string{"org", "repo", "branch", "status", "conclusion", "runner_group", "runner_labels", "workflow_name", "job_name", "job_id", "runner_id", "runner_name"},
 	)
 
-	workflowJobStatusCounter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	workflowJobStatusCounter = newSnapshotGaugeVec(prometheus.GaugeOpts{
 		Name: "github_workflow_job_status_count",
 		Help: "Count of workflow job events.",
 	},
-		[]string{"org", "repo", "branch", "status", "conclusion", "runner_group", "runner_labels", "workflow_name", "job_name", "job_id"},
+		[]string{"org", "repo", "branch", "status", "conclusion", "runner_group", "runner_labels", "workflow_name", "job_name", "job_id", "runner_id", "runner_name"},
 	)
+
+	workflowRunDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_workflow_run_duration_seconds",
+		Help:    "Duration of completed workflow runs, with the run id and url as exemplar.",
+		Buckets: durationBuckets,
+	},
+		[]string{"org", "repo", "workflow_name", "conclusion"},
+	)
+
+	workflowJobDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_workflow_job_duration_seconds",
+		Help:    "Duration of completed jobs, with the run id, job id and url as exemplar.",
+		Buckets: durationBuckets,
+	},
+		[]string{"org", "repo", "workflow_name", "job_name", "conclusion"},
+	)
+
+	durationBuckets = []float64{10, 30, 60, 120, 300, 600, 900, 1800, 3600, 7200, 14400}
 )
 
+// getExemplar - exemplar labels pointing to a run or job. The url is dropped when
+// the labels would exceed the exemplar size limit.
+func getExemplar(runId int64, jobId int64, url string) prometheus.Labels {
+	exemplar := prometheus.Labels{"run_id": strconv.FormatInt(runId, 10)}
+	if jobId != 0 {
+		exemplar["job_id"] = strconv.FormatInt(jobId, 10)
+	}
+	runes := 0
+	for name, value := range exemplar {
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if runes+utf8.RuneCountInString("url")+utf8.RuneCountInString(url) <= prometheus.ExemplarMaxRunes {
+		exemplar["url"] = url
+	}
+	return exemplar
+}
+
+// observeRunDuration - observe a completed run once, runs are seen on every refresh while in the window
+func observeRunDuration(owner string, repo string, run *github.WorkflowRun, seconds float64) {
+	if run.GetStatus() != "completed" {
+		return
+	}
+	cacheKey := "run-duration:" + owner + "/" + repo + "/" + strconv.FormatInt(run.GetID(), 10) + "/" + strconv.Itoa(run.GetRunAttempt())
+	if getCache(cacheKey) != nil {
+		return
+	}
+	workflowRunDurationHistogram.WithLabelValues(owner, repo, run.GetName(), run.GetConclusion()).(prometheus.ExemplarObserver).ObserveWithExemplar(
+		seconds, getExemplar(run.GetID(), 0, run.GetHTMLURL()),
+	)
+	setCache(cacheKey, []byte("1"), 7200)
+}
+
+// observeJobDuration - observe a completed job once
+func observeJobDuration(owner string, repo string, run *github.WorkflowRun, job *workflowJob, seconds float64) {
+	cacheKey := "job-duration:" + owner + "/" + repo + "/" + strconv.FormatInt(job.GetID(), 10)
+	if getCache(cacheKey) != nil {
+		return
+	}
+	workflowJobDurationHistogram.WithLabelValues(owner, repo, run.GetName(), job.GetName(), job.GetConclusion()).(prometheus.ExemplarObserver).ObserveWithExemplar(
+		seconds, getExemplar(run.GetID(), job.GetID(), job.GetHTMLURL()),
+	)
+	setCache(cacheKey, []byte("1"), 7200)
+}
+
 // getFieldValue return value from run element which corresponds to field
 func getFieldValue(repo string, run github.WorkflowRun, field string) string {
 	switch field {
@@ -54,7 +119,7 @@ func getFieldValue(repo string, run github.WorkflowRun, field string) string {
 	case "status":
 		return *run.Status
 	}
-	log.Printf("Tried to fetch invalid field '%s'", field)
+	slog.Warn("invalid workflow run field", "repo", repo, "field", field)
 	return ""
 }
 
@@ -67,7 +132,7 @@ func getRelevantFields(repo string, run *github.WorkflowRun) []string {
 	return result
 }
 
-func getRecentWorkflowRuns(owner string, repo string) []*github.WorkflowRun {
+func getRecentWorkflowRuns(ctx context.Context, owner string, repo string) ([]*github.WorkflowRun, error) {
 	window_start := time.Now().Add(time.Duration(-1) * time.Hour).Format(time.RFC3339)
 	opt := &github.ListWorkflowRunsOptions{
 		ListOptions: github.ListOptions{PerPage: 200},
@@ -76,14 +141,14 @@ func getRecentWorkflowRuns(owner string, repo string) []*github.WorkflowRun {
 
 	var runs []*github.WorkflowRun
 	for {
-		resp, rr, err := client.Actions.ListRepositoryWorkflowRuns(context.Background(), owner, repo, opt)
+		resp, rr, err := client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, opt)
 		if rl_err, ok := err.(*github.RateLimitError); ok {
-			log.Printf("ListRepositoryWorkflowRuns ratelimited. Pausing until %s", rl_err.Rate.Reset.Time.String())
+			logRateLimited(ctx, "ListRepositoryWorkflowRuns", rl_err)
 			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
 			continue
 		} else if err != nil {
-			log.Printf("ListRepositoryWorkflowRuns error for repo %s/%s: %s", owner, repo, err.Error())
-			return runs
+			logAPIError(ctx, "ListRepositoryWorkflowRuns", err, "org", owner, "repo", repo)
+			return runs, err
 		}
 
 		runs = append(runs, resp.WorkflowRuns...)
@@ -93,154 +158,199 @@ func getRecentWorkflowRuns(owner string, repo string) []*github.WorkflowRun {
 		opt.Page = rr.NextPage
 	}
 
-	return runs
+	return runs, nil
 }
 
-func getWorkflowJobs(owner string, repo string, runId int64) []*github.WorkflowJob {
-	opt := &github.ListWorkflowJobsOptions{
-		Filter:      "all",
-		ListOptions: github.ListOptions{PerPage: 200},
+func getWorkflowRunsByStatus(ctx context.Context, owner string, repo string, status string) ([]*github.WorkflowRun, error) {
+	opt := &github.ListWorkflowRunsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+		Status:      status,
 	}
 
-	var jobs []*github.WorkflowJob
+	var runs []*github.WorkflowRun
 	for {
-		resp, rr, err := client.Actions.ListWorkflowJobs(context.Background(), owner, repo, runId, opt)
+		resp, rr, err := client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, opt)
 		if rl_err, ok := err.(*github.RateLimitError); ok {
-			log.Printf("ListWorkflowJobs ratelimited. Pausing until %s", rl_err.Rate.Reset.Time.String())
+			logRateLimited(ctx, "ListRepositoryWorkflowRuns", rl_err)
 			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
 			continue
 		} else if err != nil {
-			log.Printf("ListWorkflowJobs error for repo %s/%s: %s", owner, repo, err.Error())
-			return jobs
+			logAPIError(ctx, "ListRepositoryWorkflowRuns", err, "org", owner, "repo", repo)
+			return runs, err
 		}
 
-		jobs = append(jobs, resp.Jobs...)
+		runs = append(runs, resp.WorkflowRuns...)
 		if rr.NextPage == 0 {
 			break
 		}
 		opt.Page = rr.NextPage
 	}
 
-	return jobs
+	return runs, nil
+}
+
+// workflowJob - github.WorkflowJob with the created_at field, not available in go-github v45
+type workflowJob struct {
+	*github.WorkflowJob
+	CreatedAt *github.Timestamp `json:"created_at,omitempty"`
+}
+
+// GetCreatedAt - time the job was queued, falls back on started_at for GitHub Enterprise
+// versions that don't return created_at
+func (j *workflowJob) GetCreatedAt() github.Timestamp {
+	if j.CreatedAt == nil {
+		return j.GetStartedAt()
+	}
+	return *j.CreatedAt
+}
+
+// workflowJobs - response of /repos/{owner}/{repo}/actions/runs/{run_id}/jobs
+type workflowJobs struct {
+	TotalCount int            `json:"total_count"`
+	Jobs       []*workflowJob `json:"jobs"`
 }
 
-func getRunUsage(owner string, repo string, runId int64) *github.WorkflowRunUsage {
+func getWorkflowJobs(ctx context.Context, owner string, repo string, runId int64) ([]*workflowJob, error) {
+	page := 1
+
+	var jobs []*workflowJob
 	for {
-		resp, _, err := client.Actions.GetWorkflowRunUsageByID(context.Background(), owner, repo, runId)
+		req, err := client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/actions/runs/%v/jobs?filter=all&per_page=200&page=%d", owner, repo, runId, page), nil)
+		if err != nil {
+			logAPIError(ctx, "ListWorkflowJobs", err, "org", owner, "repo", repo, "run_id", runId)
+			return jobs, err
+		}
+		resp := &workflowJobs{}
+		rr, err := client.Do(ctx, req, resp)
 		if rl_err, ok := err.(*github.RateLimitError); ok {
-			log.Printf("GetWorkflowRunUsageByID ratelimited. Pausing until %s", rl_err.Rate.Reset.Time.String())
+			logRateLimited(ctx, "ListWorkflowJobs", rl_err)
 			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
 			continue
 		} else if err != nil {
-			log.Printf("GetWorkflowRunUsageByID error for repo %s/%s and runId %d: %s", owner, repo, runId, err.Error())
+			logAPIError(ctx, "ListWorkflowJobs", err, "org", owner, "repo", repo, "run_id", runId)
+			return jobs, err
+		}
+
+		jobs = append(jobs, resp.Jobs...)
+		if rr.NextPage == 0 {
+			break
+		}
+		page = rr.NextPage
+	}
+
+	return jobs, nil
+}
+
+func getRunUsage(ctx context.Context, owner string, repo string, runId int64) *github.WorkflowRunUsage {
+	for {
+		resp, _, err := client.Actions.GetWorkflowRunUsageByID(ctx, owner, repo, runId)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "GetWorkflowRunUsageByID", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "GetWorkflowRunUsageByID", err, "org", owner, "repo", repo, "run_id", runId)
 			return nil
 		}
 		return resp
 	}
 }
 
-// getWorkflowRunsFromGithub - return informations and status about a workflow
-func getWorkflowRunsFromGithub() {
+// getWorkflowRun - fetch a single run, to follow runs that left the listing window before completing
+func getWorkflowRun(ctx context.Context, owner string, repo string, runId int64) (*github.WorkflowRun, *github.Response, error) {
 	for {
-		workflowRunStatusGauge.Reset()
-		workflowRunDurationGauge.Reset()
-		workflowJobDurationTotalGauge.Reset()
-		workflowJobStatusCounter.Reset()
-		total_runs := 0
-		total_jobs := 0
-
-		for _, repo := range repositories {
-			r := strings.Split(repo, "/")
-			runs := getRecentWorkflowRuns(r[0], r[1])
-			total_runs += len(runs)
-
-			for _, run := range runs {
-				var s float64 = 0
-				fields := getRelevantFields(repo, run)
-				cacheWorkflowKey := r[1] + strconv.FormatInt(run.GetWorkflowID(), 10) + run.GetHeadSHA() + strconv.FormatInt(int64(run.GetRunNumber()), 10) + run.GetStatus() + run.GetConclusion()
-				cacheWorkflowValue := getCache(cacheWorkflowKey)
-
-				if cacheWorkflowValue == nil {
-					log.Printf("Cache missed for workflow run %s/%s: %s", r[0], r[1], run.GetName())
-
-					if run.GetConclusion() == "success" {
-						s = 1
-					} else if run.GetConclusion() == "skipped" {
-						s = 2
-					} else if run.GetConclusion() == "action_required" {
-						s = 3
-					} else if run.GetConclusion() == "cancelled" {
-						s = 4
-					} else if run.GetConclusion() == "failure" {
-						s = 5
-					} else if run.GetConclusion() == "neutral" {
-						s = 6
-					} else if run.GetConclusion() == "stale" {
-						s = 7
-					} else if run.GetConclusion() == "timed_out" {
-						s = 8
-					}
-
-					workflowRunStatusGauge.WithLabelValues(fields...).Set(s)
-
-					var run_usage *github.WorkflowRunUsage = nil
-					if config.Metrics.FetchWorkflowRunUsage {
-						run_usage = getRunUsage(r[0], r[1], *run.ID)
-					}
-					if run_usage == nil { // Fallback for Github Enterprise
-						created := run.CreatedAt.Time.Unix()
-						updated := run.UpdatedAt.Time.Unix()
-						elapsed := updated - created
-						workflowRunDurationGauge.WithLabelValues(fields...).Set(float64(elapsed * 1000))
-					} else {
-						workflowRunDurationGauge.WithLabelValues(fields...).Set(float64(run_usage.GetRunDurationMS()))
-					}
-				}
-
-				jobs := getWorkflowJobs(r[0], r[1], *run.ID)
-				total_jobs += len(jobs)
-				for _, job := range jobs {
-					cacheJobKey := r[1] + strconv.FormatInt(run.GetWorkflowID(), 10) + run.GetHeadSHA() + strconv.FormatInt(int64(run.GetRunNumber()), 10) + run.GetStatus() + run.GetConclusion() + job.GetConclusion() + strconv.FormatInt(job.GetID(), 10) + job.GetStatus()
-					cacheJobValue := getCache(cacheJobKey)
-
-					if cacheJobValue == nil {
-						log.Printf("Cache missed for job run %s/%s: %s", r[0], r[1], job.GetName())
-
-						runnerLabelString := getRunnerLabelString(job.Labels)
-						if job.GetStatus() == "completed" {
-							jobSeconds := math.Max(0, job.GetCompletedAt().Time.Sub(job.GetStartedAt().Time).Seconds())
-							workflowJobDurationTotalGauge.WithLabelValues(
-								r[0], r[1], run.GetHeadBranch(), job.GetStatus(), job.GetConclusion(),
-								job.GetRunnerGroupName(), runnerLabelString, run.GetName(), job.GetName(), strconv.FormatInt(job.GetID(), 10),
-							).Set(jobSeconds * 1000)
-						}
-
-						var j float64 = 0
-						if job.GetConclusion() == "success" {
-							j = 1
-						} else if job.GetConclusion() == "failure" {
-							j = 2
-						} else if job.GetConclusion() == "cancelled" {
-							j = 3
-						} else if job.GetConclusion() == "skipped" {
-							j = 4
-						} else if job.GetConclusion() == "timed_out" {
-							j = 5
-						} else if job.GetConclusion() == "action_required" {
-							j = 6
-						} else if job.GetConclusion() == "neutral" {
-							j = 7
-						}
-						workflowJobStatusCounter.WithLabelValues(r[0], r[1], run.GetHeadBranch(), job.GetStatus(), job.GetConclusion(), job.GetRunnerGroupName(), runnerLabelString, run.GetName(), job.GetName(), strconv.FormatInt(job.GetID(), 10)).Set(j)
-					}
-					setCache(cacheJobKey, []byte("1"), 3600)
-				}
-
-				// Cache the result
-				setCache(cacheWorkflowKey, []byte("1"), 3600)
+		run, resp, err := client.Actions.GetWorkflowRunByID(ctx, owner, repo, runId)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "GetWorkflowRunByID", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+			logAPIError(ctx, "GetWorkflowRunByID", err, "org", owner, "repo", repo, "run_id", runId)
+		}
+		return run, resp, err
+	}
+}
+
+// getWorkflowRunsFromGithub - follow the workflow runs and their jobs, and export every run of the model.
+// Runs created in the last hour are listed; runs that are not completed yet are followed after that.
+// Completed runs stay exported for the workflow run retention. A run or repo whose calls failed
+// keeps its state from the previous refresh.
+func getWorkflowRunsFromGithub(ctx context.Context) error {
+	var errs []error
+	listed := map[string]bool{}
+	monitored := map[string]bool{}
+
+	for _, repo := range Repositories() {
+		monitored[repo] = true
+		r := strings.Split(repo, "/")
+		runs, err := getRecentWorkflowRuns(ctx, r[0], r[1])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, run := range runs {
+			trackRun(repo, run)
+			listed[runKey(repo, run.GetID())] = true
+		}
+	}
+
+	now := time.Now()
+	for key, t := range runModel {
+		if t.expired(monitored, now) && !listed[key] {
+			delete(runModel, key)
+			continue
+		}
+		r := strings.Split(t.repo, "/")
+		if !listed[key] && t.run.GetStatus() != "completed" {
+			run, resp, err := getWorkflowRun(ctx, r[0], r[1], t.run.GetID())
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				slog.Debug("workflow run deleted", runAttrs(t.repo, t.run)...)
+				delete(runModel, key)
+				continue
+			} else if err != nil {
+				errs = append(errs, err)
+			} else {
+				trackRun(t.repo, run)
 			}
 		}
 
-		time.Sleep(time.Duration(config.Github.Refresh) * time.Second)
+		if t.jobsOutdated() {
+			jobs, err := getWorkflowJobs(ctx, r[0], r[1], t.run.GetID())
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				t.setJobs(jobs)
+			}
+		}
+		t.complete(ctx)
 	}
+
+	workflowRunStatusGauge.Reset()
+	workflowRunDurationGauge.Reset()
+	workflowJobDurationTotalGauge.Reset()
+	workflowJobStatusCounter.Reset()
+	var observed []observedRun
+	for _, t := range runModel {
+		r := strings.Split(t.repo, "/")
+		run := t.run
+		fields := getRelevantFields(t.repo, run)
+		workflowRunStatusGauge.WithLabelValues(fields...).Set(runConclusionValue(run.GetConclusion()))
+		workflowRunDurationGauge.WithLabelValues(fields...).Set(t.durationMs())
+
+		for _, job := range t.jobs {
+			labels := []string{
+				r[0], r[1], run.GetHeadBranch(), job.GetStatus(), job.GetConclusion(),
+				job.GetRunnerGroupName(), getRunnerLabelString(job.Labels), run.GetName(), job.GetName(), strconv.FormatInt(job.GetID(), 10),
+				strconv.FormatInt(job.GetRunnerID(), 10), job.GetRunnerName(),
+			}
+			if job.GetStatus() == "completed" {
+				jobSeconds := math.Max(0, job.GetCompletedAt().Time.Sub(job.GetStartedAt().Time).Seconds())
+				workflowJobDurationTotalGauge.WithLabelValues(labels...).Set(jobSeconds * 1000)
+			}
+			workflowJobStatusCounter.WithLabelValues(labels...).Set(jobConclusionValue(job.GetConclusion()))
+		}
+		observed = append(observed, observedRun{Repo: t.repo, Run: run, Jobs: t.jobs})
+	}
+
+	setObservedRuns(observed)
+	return partial(errs)
 }
@@ -2,20 +2,56 @@ package metrics
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/chipgata/github-actions-exporter/pkg/config"
 )
 
 var (
-	repositories []string
+	// repoActivity - last activity check of each repository, only used by getRepositoriesFromGithub
+	repoActivity = map[string]repoActivityCheck{}
+
+	repositoryActivityGauge = newSnapshotGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_repository_activity_count",
+			Help: "Number of monitored repositories by activity state (active/inactive)",
+		},
+		[]string{"organization", "state"},
+	)
 )
 
-func getAllReposForOrg(orga string) []string {
+type repoActivityCheck struct {
+	active    bool
+	checkedAt time.Time
+}
+
+// isRepoSelected - apply the discovery rules of the organization on a discovered repository
+func isRepoSelected(rules config.DiscoveryRules, repo *github.Repository) bool {
+	if rules.ExcludeArchived && repo.GetArchived() {
+		return false
+	}
+	if rules.ExcludeForks && repo.GetFork() {
+		return false
+	}
+	if !rules.MatchVisibility(repo.GetVisibility()) {
+		return false
+	}
+	if rules.MaxPushAge > 0 && time.Since(repo.GetPushedAt().Time) > time.Duration(rules.MaxPushAge) {
+		return false
+	}
+	return rules.MatchName(repo.GetName()) && rules.MatchTopics(repo.Topics)
+}
+
+func getAllReposForOrg(ctx context.Context, orga string) ([]string, error) {
 	var all_repos []string
+	rules := config.Discovery().RulesFor(orga)
 
 	opt := &github.RepositoryListByOrgOptions{
 		ListOptions: github.ListOptions{
@@ -24,16 +60,19 @@ func getAllReposForOrg(orga string) []string {
 		},
 	}
 	for {
-		repos_page, resp, err := client.Repositories.ListByOrg(context.Background(), orga, opt)
+		repos_page, resp, err := client.Repositories.ListByOrg(ctx, orga, opt)
 		if rl_err, ok := err.(*github.RateLimitError); ok {
-			log.Printf("ListByOrg ratelimited. Pausing until %s", rl_err.Rate.Reset.Time.String())
+			logRateLimited(ctx, "ListByOrg", rl_err)
 			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
 			continue
 		} else if err != nil {
-			log.Printf("ListByOrg error for %s: %s", orga, err.Error())
-			break
+			logAPIError(ctx, "ListByOrg", err, "org", orga)
+			return all_repos, err
 		}
 		for _, repo := range repos_page {
+			if !isRepoSelected(rules, repo) {
+				continue
+			}
 			all_repos = append(all_repos, *repo.FullName)
 		}
 		if resp.NextPage == 0 {
@@ -41,23 +80,111 @@ func getAllReposForOrg(orga string) []string {
 		}
 		opt.ListOptions.Page = resp.NextPage
 	}
-	return all_repos
+	return all_repos, nil
 }
 
-func periodicGithubFetcher() {
+// isRepoActive - true if the repository has Actions enabled and at least one workflow.
+// Errors are ignored so that a repo is never hidden because of a failed check. Reading the
+// Actions permissions needs administration access: without it (403 / 404) the state of Actions
+// is unknown and only the workflows are checked, without accounting an API error.
+func isRepoActive(ctx context.Context, owner string, repo string) bool {
+	for {
+		permissions, resp, err := client.Repositories.GetActionsPermissions(ctx, owner, repo)
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "GetActionsPermissions", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil && resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+			slog.Debug("Actions permissions not readable, checking the workflows only", "org", owner, "repo", repo, "status", resp.StatusCode)
+		} else if err != nil {
+			logAPIError(ctx, "GetActionsPermissions", err, "org", owner, "repo", repo)
+		} else if !permissions.GetEnabled() {
+			return false
+		}
+		break
+	}
+
 	for {
+		workflows, _, err := client.Actions.ListWorkflows(ctx, owner, repo, &github.ListOptions{PerPage: 1})
+		if rl_err, ok := err.(*github.RateLimitError); ok {
+			logRateLimited(ctx, "ListWorkflows", rl_err)
+			time.Sleep(time.Until(rl_err.Rate.Reset.Time))
+			continue
+		} else if err != nil {
+			logAPIError(ctx, "ListWorkflows", err, "org", owner, "repo", repo)
+			return true
+		}
+		return workflows.GetTotalCount() > 0
+	}
+}
+
+// filterActiveRepos - drop repositories without workflows or with Actions disabled.
+// Each repository is rechecked once its last check is older than RepoActivityRefresh.
+func filterActiveRepos(ctx context.Context, repos []string) []string {
+	var active []string
+	counts := map[string]map[string]int{}
+	checked := map[string]repoActivityCheck{}
+
+	for _, repo := range repos {
+		check, ok := repoActivity[repo]
+		if !ok || time.Since(check.checkedAt) > time.Duration(config.Github.RepoActivityRefresh)*time.Second {
+			r := strings.Split(repo, "/")
+			check = repoActivityCheck{active: isRepoActive(ctx, r[0], r[1]), checkedAt: time.Now()}
+		}
+		checked[repo] = check
 
-		// Fetch repositories (if dynamic)
-		var repos_to_fetch []string
-		if len(config.Github.Repositories.Value()) > 0 {
-			repos_to_fetch = config.Github.Repositories.Value()
+		orga := strings.Split(repo, "/")[0]
+		if counts[orga] == nil {
+			counts[orga] = map[string]int{}
+		}
+		if check.active {
+			active = append(active, repo)
+			counts[orga]["active"]++
 		} else {
-			for _, orga := range config.Github.Organizations.Value() {
-				repos_to_fetch = append(repos_to_fetch, getAllReposForOrg(orga)...)
-			}
+			counts[orga]["inactive"]++
 		}
-		repositories = repos_to_fetch
+	}
+	// Forget repositories that are not monitored anymore
+	repoActivity = checked
+
+	repositoryActivityGauge.Reset()
+	for orga, states := range counts {
+		repositoryActivityGauge.WithLabelValues(orga, "active").Set(float64(states["active"]))
+		repositoryActivityGauge.WithLabelValues(orga, "inactive").Set(float64(states["inactive"]))
+	}
+	return active
+}
 
-		time.Sleep(time.Duration(config.Github.Refresh) * 5 * time.Second)
+// getRepositoriesFromGithub - refresh the list of repositories the other collectors iterate on.
+// If listing the repositories of an organization fails, its repositories from the previous list
+// are kept. If no repository could be listed at all, the previous list is kept as a whole.
+func getRepositoriesFromGithub(ctx context.Context) error {
+	// Fetch repositories (if dynamic)
+	var repos_to_fetch []string
+	var errs []error
+	if len(config.Repositories()) > 0 {
+		repos_to_fetch = config.Repositories()
+	} else {
+		for _, orga := range config.Organizations() {
+			repos, err := getAllReposForOrg(ctx, orga)
+			if err != nil {
+				errs = append(errs, err)
+				for _, repo := range Repositories() {
+					if strings.HasPrefix(repo, orga+"/") {
+						repos_to_fetch = append(repos_to_fetch, repo)
+					}
+				}
+				continue
+			}
+			repos_to_fetch = append(repos_to_fetch, repos...)
+		}
+	}
+	if len(repos_to_fetch) == 0 && len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if config.Github.CheckRepoActivity {
+		repos_to_fetch = filterActiveRepos(ctx, repos_to_fetch)
 	}
+	setRepositories(repos_to_fetch)
+	return partial(errs)
 }
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// fakeActivity - Github API answering the Actions permissions and workflows of foo/<repo>
+// from the status and the number of workflows of each repo, counting the calls
+type fakeActivity struct {
+	mu          sync.Mutex
+	permissions map[string]int
+	enabled     map[string]bool
+	workflows   map[string]int
+	calls       map[string]int
+}
+
+func (f *fakeActivity) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[r.URL.Path]++
+	repo, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/repos/foo/"), "/")
+	switch endpoint {
+	case "actions/permissions":
+		if status := f.permissions[repo]; status != 0 && status != http.StatusOK {
+			http.Error(w, `{"message": "Resource not accessible by integration"}`, status)
+			return
+		}
+		fmt.Fprintf(w, `{"enabled": %v, "allowed_actions": "all"}`, f.enabled[repo])
+	case "actions/workflows":
+		fmt.Fprintf(w, `{"total_count": %d, "workflows": []}`, f.workflows[repo])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestIsRepoActive(t *testing.T) {
+	fake := &fakeActivity{
+		permissions: map[string]int{"forbidden": http.StatusForbidden, "hidden": http.StatusNotFound, "broken": http.StatusInternalServerError},
+		enabled:     map[string]bool{"active": true, "no-workflows": true, "broken": true},
+		workflows:   map[string]int{"active": 2, "disabled": 3, "forbidden": 1, "hidden": 0, "broken": 1},
+		calls:       map[string]int{},
+	}
+	fakeGithub(t, fake)
+
+	for _, tc := range []struct {
+		repo   string
+		active bool
+		// apiErrors - API errors accounted by the check
+		apiErrors int64
+	}{
+		{"active", true, 0},
+		{"disabled", false, 0},
+		{"no-workflows", false, 0},
+		// Administration access missing, decided from the workflows only
+		{"forbidden", true, 0},
+		{"hidden", false, 0},
+		{"broken", true, 1},
+	} {
+		before := apiErrorsTotal.Load()
+		if active := isRepoActive(context.Background(), "foo", tc.repo); active != tc.active {
+			t.Errorf("isRepoActive(foo/%s) = %v, want %v", tc.repo, active, tc.active)
+		}
+		if apiErrors := apiErrorsTotal.Load() - before; apiErrors != tc.apiErrors {
+			t.Errorf("isRepoActive(foo/%s) accounted %d API errors, want %d", tc.repo, apiErrors, tc.apiErrors)
+		}
+	}
+	if calls := fake.calls["/repos/foo/disabled/actions/workflows"]; calls != 0 {
+		t.Errorf("workflows of a repo with Actions disabled listed %d times, want 0", calls)
+	}
+}
+
+func TestFilterActiveRepos(t *testing.T) {
+	fake := &fakeActivity{
+		enabled:   map[string]bool{"a": true, "b": true},
+		workflows: map[string]int{"a": 1, "b": 0},
+		calls:     map[string]int{},
+	}
+	fakeGithub(t, fake)
+	config.Github.RepoActivityRefresh = 3600
+	t.Cleanup(func() { repoActivity = map[string]repoActivityCheck{} })
+
+	if active := filterActiveRepos(context.Background(), []string{"foo/a", "foo/b"}); len(active) != 1 || active[0] != "foo/a" {
+		t.Errorf("first check = %v, want [foo/a]", active)
+	}
+
+	// Within the refresh, the previous checks are used
+	fake.mu.Lock()
+	fake.workflows["b"] = 1
+	fake.mu.Unlock()
+	if active := filterActiveRepos(context.Background(), []string{"foo/a", "foo/b"}); len(active) != 1 {
+		t.Errorf("check within the refresh = %v, want [foo/a]", active)
+	}
+	if calls := fake.calls["/repos/foo/b/actions/workflows"]; calls != 1 {
+		t.Errorf("foo/b workflows listed %d times within the refresh, want 1", calls)
+	}
+
+	// Once the check of foo/b is older than the refresh, it is checked again
+	check := repoActivity["foo/b"]
+	check.checkedAt = time.Now().Add(-2 * time.Hour)
+	repoActivity["foo/b"] = check
+	if active := filterActiveRepos(context.Background(), []string{"foo/a", "foo/b"}); len(active) != 2 {
+		t.Errorf("recheck = %v, want [foo/a foo/b]", active)
+	}
+	if calls := fake.calls["/repos/foo/a/actions/workflows"]; calls != 1 {
+		t.Errorf("foo/a workflows listed %d times, want 1", calls)
+	}
+
+	// Repositories no longer monitored are forgotten
+	filterActiveRepos(context.Background(), []string{"foo/a"})
+	if _, ok := repoActivity["foo/b"]; ok {
+		t.Error("foo/b still tracked once no longer monitored")
+	}
+}
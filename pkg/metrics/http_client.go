@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newBaseTransport - transport of the Github API requests, below the HTTP cache and the retries,
+// with the proxy, TLS and timeouts of the configuration. Used by both the token and the App
+// authentication, including the App access token requests.
+func newBaseTransport() (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.Github.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.Github.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("github_proxy_url incorrect: %v", err)
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  proxyURL.String(),
+			HTTPSProxy: proxyURL.String(),
+			NoProxy:    config.Github.NoProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if config.Github.ConnectTimeout > 0 {
+		connectTimeout := time.Duration(config.Github.ConnectTimeout) * time.Second
+		transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+
+	if config.Github.RequestTimeout > 0 {
+		return timeoutTransport{next: transport, timeout: time.Duration(config.Github.RequestTimeout) * time.Second}, nil
+	}
+	return transport, nil
+}
+
+// newTLSConfig - system CAs plus github_ca_file, and the client certificate if any
+func newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFiles := config.Github.CAFiles.Value(); len(caFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, caFile := range caFiles {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading github_ca_file failed: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("github_ca_file %s: no PEM certificate found", caFile)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.Github.ClientCertFile != "" || config.Github.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.Github.ClientCertFile, config.Github.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading github_client_cert_file / github_client_key_file failed: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// timeoutTransport - bound each request, until its response body is read, to timeout.
+// Below the retries, so that every attempt has its own timeout. The timeout is released once
+// the body is read to the end or closed, or right away without body: httpcache never closes
+// the body of a 304 it replaces with the cached response.
+type timeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		cancel()
+		return resp, nil
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose - response body releasing the timeout of its request once read or closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.cancel()
+	}
+	return n, err
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
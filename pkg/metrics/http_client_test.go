@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTimeoutTransportReleasesContext(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		resp func() *http.Response
+		// read - consume the body, the request context must be released after
+		read func(resp *http.Response)
+	}{
+		{
+			name: "304 without body, never closed",
+			resp: func() *http.Response { return &http.Response{StatusCode: http.StatusNotModified, Body: http.NoBody} },
+			read: func(resp *http.Response) {},
+		},
+		{
+			name: "body read to EOF, not closed",
+			resp: func() *http.Response {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}
+			},
+			read: func(resp *http.Response) { io.ReadAll(resp.Body) },
+		},
+		{
+			name: "body closed before EOF",
+			resp: func() *http.Response {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}
+			},
+			read: func(resp *http.Response) { resp.Body.Close() },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ctx context.Context
+			transport := timeoutTransport{
+				timeout: time.Hour,
+				next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					ctx = req.Context()
+					return tc.resp(), nil
+				}),
+			}
+			req, _ := http.NewRequest("GET", "http://localhost/", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Body != http.NoBody && ctx.Err() != nil {
+				t.Fatal("request context released before the body was read")
+			}
+			tc.read(resp)
+			if ctx.Err() != context.Canceled {
+				t.Errorf("request context error = %v, want released", ctx.Err())
+			}
+		})
+	}
+}
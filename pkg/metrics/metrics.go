@@ -3,12 +3,15 @@ package metrics
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/logging"
 
 	"github.com/coocood/freecache"
 
@@ -24,26 +27,50 @@ var (
 	cache                    *freecache.Cache
 	client                   *github.Client
 	err                      error
-	workflowRunStatusGauge   *prometheus.GaugeVec
-	workflowRunDurationGauge *prometheus.GaugeVec
+	workflowRunStatusGauge   *snapshotGaugeVec
+	workflowRunDurationGauge *snapshotGaugeVec
+
+	apiErrorsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_exporter_api_errors_total",
+			Help: "Number of failed Github API calls, by endpoint",
+		},
+		[]string{"endpoint"},
+	)
+	// apiErrorsTotal - number of failed Github API calls since start
+	apiErrorsTotal atomic.Int64
 )
 
 // InitMetrics - register metrics in prometheus lib and start func for monitor
 func InitMetrics() {
+	registerMetrics()
+	if err := initClient(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	for _, c := range collectors {
+		c.trigger = make(chan chan struct{})
+	}
+	go startCollectors()
+}
+
+// registerMetrics - create the cache and register metrics in prometheus lib
+func registerMetrics() {
 	cacheSize := 100 * 1024 * 1024
 	cache = freecache.NewCache(cacheSize)
 
-	workflowRunStatusGauge = prometheus.NewGaugeVec(
+	workflowRunStatusGauge = newSnapshotGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "github_workflow_run_status",
-			Help: "Workflow run status of all workflow runs created in the last 1hr",
+			Help: "Workflow run status of the workflow runs created in the last 1hr, then kept for the workflow run retention",
 		},
 		strings.Split(config.WorkflowFields, ","),
 	)
-	workflowRunDurationGauge = prometheus.NewGaugeVec(
+	workflowRunDurationGauge = newSnapshotGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "github_workflow_run_duration_ms",
-			Help: "Workflow run duration (in milliseconds) of all workflow runs created in the last 1hr",
+			Help: "Workflow run duration (in milliseconds) of the workflow runs created in the last 1hr, then kept for the workflow run retention",
 		},
 		strings.Split(config.WorkflowFields, ","),
 	)
@@ -55,19 +82,65 @@ func InitMetrics() {
 
 	prometheus.MustRegister(workflowJobDurationTotalGauge)
 	prometheus.MustRegister(workflowJobStatusCounter)
+	prometheus.MustRegister(workflowRunDurationHistogram)
+	prometheus.MustRegister(workflowJobDurationHistogram)
+	prometheus.MustRegister(runnerJobBusySecondsCounter)
+	prometheus.MustRegister(runnerJobsCounter)
+	prometheus.MustRegister(runnerGroupJobBusySecondsCounter)
+	prometheus.MustRegister(runnerGroupJobsCounter)
+	prometheus.MustRegister(jobQueueGauge)
+	prometheus.MustRegister(jobQueueOldestAgeGauge)
 	prometheus.MustRegister(rateLimitGauge)
+	prometheus.MustRegister(repositoryActivityGauge)
+	prometheus.MustRegister(artifactCountGauge)
+	prometheus.MustRegister(artifactSizeGauge)
+	prometheus.MustRegister(artifactStatusGauge)
+	prometheus.MustRegister(artifactOldestAgeGauge)
+	prometheus.MustRegister(cacheUsageCountGauge)
+	prometheus.MustRegister(cacheUsageSizeGauge)
+	prometheus.MustRegister(cacheUsageOrganizationCountGauge)
+	prometheus.MustRegister(cacheUsageOrganizationSizeGauge)
+	prometheus.MustRegister(deploymentStatusGauge)
+	prometheus.MustRegister(deploymentApprovalWaitGauge)
+	prometheus.MustRegister(deploymentPendingGauge)
+	prometheus.MustRegister(deploymentPendingWaitGauge)
+	prometheus.MustRegister(apiErrorsCounter)
+	prometheus.MustRegister(apiNotModifiedCounter)
+	prometheus.MustRegister(apiRetriesCounter)
+	prometheus.MustRegister(apiRetryGiveUpsCounter)
+	prometheus.MustRegister(collectorStaleGauge)
+	prometheus.MustRegister(collectorLastSuccessGauge)
+	prometheus.MustRegister(configReloadSuccessGauge)
+	prometheus.MustRegister(configReloadTimestampGauge)
+}
 
+// initClient - create the shared Github client
+func initClient() error {
 	client, err = NewClient()
 	if err != nil {
-		log.Fatalln("Error: Client creation failed." + err.Error())
+		return fmt.Errorf("Error: Client creation failed.%s", err.Error())
 	}
+	return nil
+}
 
-	go periodicGithubFetcher()
-	go getRunnersFromGithub()
-	go getRunnersOrganizationFromGithub()
-	go getWorkflowRunsFromGithub()
-	go getRunnersEnterpriseFromGithub()
-	go getRateLimitFromGithub()
+// logAPIError - log a failed Github API call of the collector of ctx and count it.
+// args are slog key / value pairs locating the call (org, repo, run_id, ...).
+func logAPIError(ctx context.Context, endpoint string, err error, args ...any) {
+	attrs := append([]any{"collector", collectorName(ctx), "endpoint", endpoint}, args...)
+	logging.ErrorLimited(fmt.Sprintln(attrs...), "Github API call failed", append(attrs, "error", err)...)
+
+	var location strings.Builder
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&location, " %v=%v", args[i], args[i+1])
+	}
+	recordAPIError(endpoint, fmt.Sprintf("%s error%s: %v", endpoint, location.String(), err))
+	apiErrorsCounter.WithLabelValues(endpoint).Inc()
+	apiErrorsTotal.Add(1)
+}
+
+// logRateLimited - log a pause until the rate limit resets
+func logRateLimited(ctx context.Context, endpoint string, err *github.RateLimitError) {
+	slog.Warn("Github API rate limited, pausing", "collector", collectorName(ctx), "endpoint", endpoint, "until", err.Rate.Reset.Time)
 }
 
 // NewClient creates a Github Client
@@ -78,16 +151,28 @@ func NewClient() (*github.Client, error) {
 		cachedTransport *httpcache.Transport
 	)
 
-	cache := lrucache.New(config.Github.CacheSizeBytes, 0)
-	cachedTransport = httpcache.NewTransport(cache)
+	var httpCache httpcache.Cache = lrucache.New(config.Github.CacheSizeBytes, 0)
+	if config.Github.CacheDir != "" {
+		diskCache, err := newDiskCache(config.Github.CacheDir, config.Github.CacheDirSizeBytes)
+		if err != nil {
+			return nil, fmt.Errorf("opening Github HTTP cache failed: %v", err)
+		}
+		httpCache = diskCache
+	}
+	baseTransport, err := newBaseTransport()
+	if err != nil {
+		return nil, err
+	}
+	cachedTransport = httpcache.NewTransport(httpCache)
+	cachedTransport.Transport = notModifiedTransport{next: retryTransport{next: baseTransport}}
 
 	if len(config.Github.Token) > 0 {
-		log.Printf("authenticating with Github Token")
+		slog.Info("authenticating with Github Token")
 		ctx := context.Background()
-		ctx = context.WithValue(ctx, "HTTPClient", cachedTransport.Client())
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, cachedTransport.Client())
 		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Github.Token}))
 	} else {
-		log.Printf("authenticating with Github App")
+		slog.Info("authenticating with Github App")
 		transport, err := ghinstallation.NewKeyFromFile(cachedTransport, config.Github.AppID, config.Github.AppInstallationID, config.Github.AppPrivateKey)
 		if err != nil {
 			return nil, fmt.Errorf("authentication failed: %v", err)
@@ -103,7 +188,6 @@ func NewClient() (*github.Client, error) {
 	}
 
 	if config.Github.APIURL != "api.github.com" {
-		var err error
 		client, err = github.NewEnterpriseClient(config.Github.APIURL, config.Github.APIURL, httpClient)
 		if err != nil {
 			return nil, fmt.Errorf("enterprise client creation failed: %v", err)
@@ -146,14 +230,13 @@ func getRunnerLabelString(labels []string) string {
 
 func setCache(key string, value []byte, ttl int) {
 	if err := cache.Set([]byte(key), value, ttl); err != nil {
-		log.Printf("setCache: Error setting cache for key %s: %v", key, err)
+		slog.Error("setting cache failed", "key", key, "error", err)
 	}
 }
 
 func getCache(key string) []byte {
 	value, err := cache.Get([]byte(key))
 	if err != nil {
-		log.Printf("getCache: Error getting cache for key %s: %v", key, err)
 		return nil
 	}
 	return value
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// reloadMu - one reload of the targets at a time
+	reloadMu sync.Mutex
+
+	configReloadSuccessGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "github_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful",
+		},
+	)
+	configReloadTimestampGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "github_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload",
+		},
+	)
+)
+
+// RecordConfigReload - account a configuration reload attempt
+func RecordConfigReload(success bool) {
+	if !success {
+		configReloadSuccessGauge.Set(0)
+		return
+	}
+	configReloadSuccessGauge.Set(1)
+	configReloadTimestampGauge.SetToCurrentTime()
+}
+
+// ReloadTargets - refresh the repositories after a configuration reload, delete the series of the
+// repositories and organizations no longer monitored, then refresh every other collector
+func ReloadTargets(previousOrgs []string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	previousRepos := Repositories()
+	collectors[0].collectNow()
+
+	removedRepos := difference(previousRepos, Repositories())
+	removedOrgs := difference(previousOrgs, config.Organizations())
+	if len(removedRepos) > 0 || len(removedOrgs) > 0 {
+		slog.Info("deleting series no longer monitored", "repositories", removedRepos, "orgs", removedOrgs)
+	}
+	for _, repo := range removedRepos {
+		deleteRepoSeries(repo)
+	}
+	for _, orga := range removedOrgs {
+		deleteOrgSeries(orga)
+	}
+
+	for _, c := range collectors[1:] {
+		if c.enabled() {
+			go c.collectNow()
+		}
+	}
+}
+
+// deleteRepoSeries - delete the series of a repository (<orga>/<repo>)
+func deleteRepoSeries(repo string) {
+	byFullName := prometheus.Labels{"repo": repo}
+	for _, vec := range []*snapshotGaugeVec{
+		runnersGauge, artifactCountGauge, artifactSizeGauge, artifactStatusGauge, artifactOldestAgeGauge,
+		cacheUsageCountGauge, cacheUsageSizeGauge, deploymentStatusGauge, deploymentApprovalWaitGauge,
+		deploymentPendingGauge, deploymentPendingWaitGauge, workflowRunStatusGauge, workflowRunDurationGauge,
+	} {
+		vec.DeletePartialMatch(byFullName)
+	}
+
+	r := strings.Split(repo, "/")
+	byOrgAndName := prometheus.Labels{"org": r[0], "repo": r[1]}
+	workflowJobDurationTotalGauge.DeletePartialMatch(byOrgAndName)
+	workflowJobStatusCounter.DeletePartialMatch(byOrgAndName)
+	workflowRunDurationHistogram.DeletePartialMatch(byOrgAndName)
+	workflowJobDurationHistogram.DeletePartialMatch(byOrgAndName)
+}
+
+// deleteOrgSeries - delete the organization level series of an organization
+func deleteOrgSeries(orga string) {
+	for _, vec := range []*snapshotGaugeVec{
+		runnersOrganizationGauge, cacheUsageOrganizationCountGauge, cacheUsageOrganizationSizeGauge, repositoryActivityGauge,
+	} {
+		vec.DeletePartialMatch(prometheus.Labels{"organization": orga})
+	}
+	jobQueueGauge.DeletePartialMatch(prometheus.Labels{"org": orga})
+	jobQueueOldestAgeGauge.DeletePartialMatch(prometheus.Labels{"org": orga})
+}
+
+// difference - values of a not in b
+func difference(a []string, b []string) []string {
+	in := map[string]bool{}
+	for _, v := range b {
+		in[v] = true
+	}
+	var result []string
+	for _, v := range a {
+		if !in[v] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiRetriesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_exporter_api_retries_total",
+			Help: "Number of Github API requests retried, by reason (secondary_rate_limit, server_error, timeout)",
+		},
+		[]string{"reason"},
+	)
+	apiRetryGiveUpsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_exporter_api_retry_give_ups_total",
+			Help: "Number of Github API requests that still failed after their retries, by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+// secondaryRateLimitWait - wait before retrying after a secondary rate limit without Retry-After, as advised by Github
+const secondaryRateLimitWait = time.Minute
+
+// retryTransport - retry the requests failing with a secondary rate limit, a 502 / 503 / 504 or a timeout,
+// up to config.Github.Retries times. Retry-After is honoured, otherwise the wait is an exponential
+// backoff with jitter. Primary rate limits are not retried here, the collectors wait for the reset.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		reason, wait := retryReason(resp, err, attempt)
+		if reason == "" {
+			return resp, err
+		}
+
+		giveUp := ""
+		switch {
+		case attempt >= config.Github.Retries:
+			giveUp = "retries exhausted"
+		case wait > time.Duration(config.Github.RetryMaxWait)*time.Second:
+			giveUp = "wait over github_retry_max_wait"
+		case req.Body != nil && req.GetBody == nil:
+			giveUp = "request body can't be replayed"
+		}
+		if giveUp != "" {
+			if attempt > 0 || config.Github.Retries > 0 {
+				apiRetryGiveUpsCounter.WithLabelValues(reason).Inc()
+				slog.Warn("Github API request failed, giving up", "path", req.URL.Path, "reason", reason, "attempts", attempt+1, "cause", giveUp)
+			}
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		apiRetriesCounter.WithLabelValues(reason).Inc()
+		slog.Warn("Github API request failed, retrying", "path", req.URL.Path, "reason", reason, "attempt", attempt+1, "wait", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryReason - why the request should be retried and how long to wait before, reason is empty when it should not
+func retryReason(resp *http.Response, err error, attempt int) (string, time.Duration) {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "timeout", backoff(attempt)
+		}
+		return "", 0
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if wait, ok := retryAfter(resp); ok {
+			return "server_error", wait
+		}
+		return "server_error", backoff(attempt)
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if wait, ok := retryAfter(resp); ok {
+			return "secondary_rate_limit", wait
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			// Primary rate limit
+			return "", 0
+		}
+		if isSecondaryRateLimit(resp) {
+			return "secondary_rate_limit", secondaryRateLimitWait<<uint(attempt) + jitter(secondaryRateLimitWait)
+		}
+	}
+	return "", 0
+}
+
+// backoff - 1s, 2s, 4s, ... with jitter
+func backoff(attempt int) time.Duration {
+	wait := time.Duration(1<<uint(attempt)) * time.Second
+	return wait + jitter(wait)
+}
+
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(d) / 2))
+}
+
+// retryAfter - the wait requested by the Retry-After header, in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(date)), true
+	}
+	return 0, false
+}
+
+// isSecondaryRateLimit - true if the error message of a 403 / 429 is about a secondary rate limit.
+// The body is read and put back for the caller.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	message := strings.ToLower(string(body))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse")
+}
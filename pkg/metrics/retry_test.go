@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// timeoutError - a net.Error timing out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// fakeResponse - response with a status, headers as name / value pairs and a body
+func fakeResponse(status int, body string, headers ...string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	for i := 0; i+1 < len(headers); i += 2 {
+		resp.Header.Set(headers[i], headers[i+1])
+	}
+	return resp
+}
+
+func TestRetryReason(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		resp   *http.Response
+		err    error
+		reason string
+		// minWait, maxWait - bounds of the wait, jitter included
+		minWait time.Duration
+		maxWait time.Duration
+	}{
+		{"200", fakeResponse(http.StatusOK, "{}"), nil, "", 0, 0},
+		{"404", fakeResponse(http.StatusNotFound, "{}"), nil, "", 0, 0},
+		{"502 with backoff", fakeResponse(http.StatusBadGateway, ""), nil, "server_error", time.Second, 1500 * time.Millisecond},
+		{"503 with backoff", fakeResponse(http.StatusServiceUnavailable, ""), nil, "server_error", time.Second, 1500 * time.Millisecond},
+		{"504 with Retry-After", fakeResponse(http.StatusGatewayTimeout, "", "Retry-After", "7"), nil, "server_error", 7 * time.Second, 7 * time.Second},
+		{"500 not retried", fakeResponse(http.StatusInternalServerError, ""), nil, "", 0, 0},
+		{"Retry-After in seconds", fakeResponse(http.StatusForbidden, "", "Retry-After", "30"), nil, "secondary_rate_limit", 30 * time.Second, 30 * time.Second},
+		{
+			"Retry-After as an HTTP date",
+			fakeResponse(http.StatusTooManyRequests, "", "Retry-After", time.Now().Add(2*time.Minute).UTC().Format(http.TimeFormat)),
+			nil, "secondary_rate_limit", 118 * time.Second, 2 * time.Minute,
+		},
+		{
+			"primary rate limit 403",
+			fakeResponse(http.StatusForbidden, `{"message": "API rate limit exceeded"}`, "X-RateLimit-Remaining", "0"),
+			nil, "", 0, 0,
+		},
+		{
+			"secondary rate limit 403",
+			fakeResponse(http.StatusForbidden, `{"message": "You have exceeded a secondary rate limit"}`, "X-RateLimit-Remaining", "4000"),
+			nil, "secondary_rate_limit", secondaryRateLimitWait, secondaryRateLimitWait * 3 / 2,
+		},
+		{
+			"permission 403",
+			fakeResponse(http.StatusForbidden, `{"message": "Resource not accessible by integration"}`),
+			nil, "", 0, 0,
+		},
+		{"timeout", nil, timeoutError{}, "timeout", time.Second, 1500 * time.Millisecond},
+		{"connection refused", nil, io.ErrUnexpectedEOF, "", 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, wait := retryReason(tc.resp, tc.err, 0)
+			if reason != tc.reason {
+				t.Errorf("reason = %q, want %q", reason, tc.reason)
+			}
+			if wait < tc.minWait || wait > tc.maxWait {
+				t.Errorf("wait = %s, want between %s and %s", wait, tc.minWait, tc.maxWait)
+			}
+			if tc.resp != nil {
+				// The body is still readable by the caller
+				if _, err := io.ReadAll(tc.resp.Body); err != nil {
+					t.Errorf("body not readable: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if wait := backoff(attempt); wait < want || wait >= want*3/2 {
+			t.Errorf("backoff(%d) = %s, want %s plus up to 50%% jitter", attempt, wait, want)
+		}
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	defer func(retries int, maxWait int64) {
+		config.Github.Retries, config.Github.RetryMaxWait = retries, maxWait
+	}(config.Github.Retries, config.Github.RetryMaxWait)
+
+	for _, tc := range []struct {
+		name    string
+		retries int
+		maxWait int64
+		// responses - successive responses, the last one repeated
+		responses []func() *http.Response
+		status    int
+		attempts  int
+		retried   float64
+		gaveUp    float64
+	}{
+		{
+			name:    "retried until success",
+			retries: 3, maxWait: 60,
+			responses: []func() *http.Response{
+				func() *http.Response { return fakeResponse(http.StatusBadGateway, "", "Retry-After", "0") },
+				func() *http.Response { return fakeResponse(http.StatusServiceUnavailable, "", "Retry-After", "0") },
+				func() *http.Response { return fakeResponse(http.StatusOK, "{}") },
+			},
+			status: http.StatusOK, attempts: 3, retried: 2, gaveUp: 0,
+		},
+		{
+			name:    "retries exhausted",
+			retries: 2, maxWait: 60,
+			responses: []func() *http.Response{
+				func() *http.Response { return fakeResponse(http.StatusGatewayTimeout, "", "Retry-After", "0") },
+			},
+			status: http.StatusGatewayTimeout, attempts: 3, retried: 2, gaveUp: 1,
+		},
+		{
+			name:    "wait over the max wait",
+			retries: 3, maxWait: 60,
+			responses: []func() *http.Response{
+				func() *http.Response { return fakeResponse(http.StatusForbidden, "", "Retry-After", "120") },
+			},
+			status: http.StatusForbidden, attempts: 1, retried: 0, gaveUp: 1,
+		},
+		{
+			name:    "retries disabled",
+			retries: 0, maxWait: 60,
+			responses: []func() *http.Response{
+				func() *http.Response { return fakeResponse(http.StatusBadGateway, "", "Retry-After", "0") },
+			},
+			status: http.StatusBadGateway, attempts: 1, retried: 0, gaveUp: 0,
+		},
+		{
+			name:    "not retryable",
+			retries: 3, maxWait: 60,
+			responses: []func() *http.Response{
+				func() *http.Response { return fakeResponse(http.StatusNotFound, "{}") },
+			},
+			status: http.StatusNotFound, attempts: 1, retried: 0, gaveUp: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Github.Retries, config.Github.RetryMaxWait = tc.retries, tc.maxWait
+			attempts := 0
+			transport := retryTransport{next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				resp := tc.responses[min(attempts, len(tc.responses)-1)]()
+				attempts++
+				return resp, nil
+			})}
+			counted := func() (float64, float64) {
+				var retried, gaveUp float64
+				for _, reason := range []string{"server_error", "secondary_rate_limit", "timeout"} {
+					retried += testutil.ToFloat64(apiRetriesCounter.WithLabelValues(reason))
+					gaveUp += testutil.ToFloat64(apiRetryGiveUpsCounter.WithLabelValues(reason))
+				}
+				return retried, gaveUp
+			}
+			retriedBefore, gaveUpBefore := counted()
+
+			req, _ := http.NewRequest("GET", "http://localhost/repos/foo/svc", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.status)
+			}
+			if attempts != tc.attempts {
+				t.Errorf("%d attempts, want %d", attempts, tc.attempts)
+			}
+			retried, gaveUp := counted()
+			if retried-retriedBefore != tc.retried || gaveUp-gaveUpBefore != tc.gaveUp {
+				t.Errorf("retried %v, gave up %v, want %v and %v", retried-retriedBefore, gaveUp-gaveUpBefore, tc.retried, tc.gaveUp)
+			}
+		})
+	}
+}
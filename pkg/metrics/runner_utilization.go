@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	runnerJobBusySecondsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_runner_job_busy_seconds_total",
+			Help: "Total time (in seconds) a runner spent executing completed jobs",
+		},
+		[]string{"runner_id", "runner_name", "runner_group"},
+	)
+	runnerJobsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_runner_jobs_total",
+			Help: "Total number of completed jobs executed by a runner",
+		},
+		[]string{"runner_id", "runner_name", "runner_group"},
+	)
+	runnerGroupJobBusySecondsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_runner_group_job_busy_seconds_total",
+			Help: "Total time (in seconds) runners of a group and label set spent executing completed jobs",
+		},
+		[]string{"runner_group", "runner_labels"},
+	)
+	runnerGroupJobsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_runner_group_jobs_total",
+			Help: "Total number of completed jobs executed by runners of a group and label set",
+		},
+		[]string{"runner_group", "runner_labels"},
+	)
+)
+
+// recordRunnerUtilization - account a completed job to the runner that executed it.
+// Each job is only accounted once, jobs are seen on every refresh while their run is in the window.
+func recordRunnerUtilization(owner string, repo string, job *workflowJob) {
+	if job.GetStatus() != "completed" || job.GetRunnerID() == 0 {
+		return
+	}
+	cacheKey := "runner-job:" + owner + "/" + repo + "/" + strconv.FormatInt(job.GetID(), 10)
+	if getCache(cacheKey) != nil {
+		return
+	}
+
+	busySeconds := math.Max(0, job.GetCompletedAt().Time.Sub(job.GetStartedAt().Time).Seconds())
+	runnerId := strconv.FormatInt(job.GetRunnerID(), 10)
+	runnerLabelString := getRunnerLabelString(job.Labels)
+
+	runnerJobBusySecondsCounter.WithLabelValues(runnerId, job.GetRunnerName(), job.GetRunnerGroupName()).Add(busySeconds)
+	runnerJobsCounter.WithLabelValues(runnerId, job.GetRunnerName(), job.GetRunnerGroupName()).Inc()
+	runnerGroupJobBusySecondsCounter.WithLabelValues(job.GetRunnerGroupName(), runnerLabelString).Add(busySeconds)
+	runnerGroupJobsCounter.WithLabelValues(job.GetRunnerGroupName(), runnerLabelString).Inc()
+
+	// Runs are fetched over a 1hr window, keep the key a bit longer than that
+	setCache(cacheKey, []byte("1"), 7200)
+}
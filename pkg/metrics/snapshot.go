@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	collectorStaleGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_exporter_collector_stale",
+			Help: "1 if the last collection cycle failed and the metrics of the collector are from an earlier cycle",
+		},
+		[]string{"collector"},
+	)
+	collectorLastSuccessGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_exporter_collector_last_success_timestamp_seconds",
+			Help: "Timestamp of the last complete collection cycle of the collector",
+		},
+		[]string{"collector"},
+	)
+)
+
+// snapshotGaugeVec - a GaugeVec filled during a collection cycle and served once the cycle is
+// complete. Scrapes see the last complete snapshot, never a partially filled one.
+type snapshotGaugeVec struct {
+	opts       prometheus.GaugeOpts
+	labelNames []string
+
+	// mu - guards building, swapped by Reset and publish while a collector fills it
+	mu        sync.Mutex
+	building  *prometheus.GaugeVec
+	published atomic.Pointer[prometheus.GaugeVec]
+}
+
+func newSnapshotGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *snapshotGaugeVec {
+	s := &snapshotGaugeVec{opts: opts, labelNames: labelNames}
+	s.building = prometheus.NewGaugeVec(opts, labelNames)
+	s.published.Store(prometheus.NewGaugeVec(opts, labelNames))
+	return s
+}
+
+// Describe - implements prometheus.Collector
+func (s *snapshotGaugeVec) Describe(ch chan<- *prometheus.Desc) {
+	s.published.Load().Describe(ch)
+}
+
+// Collect - implements prometheus.Collector, serves the last published snapshot
+func (s *snapshotGaugeVec) Collect(ch chan<- prometheus.Metric) {
+	s.published.Load().Collect(ch)
+}
+
+// Reset - start a new snapshot, the published one is served until the next publish
+func (s *snapshotGaugeVec) Reset() {
+	s.mu.Lock()
+	s.building = prometheus.NewGaugeVec(s.opts, s.labelNames)
+	s.mu.Unlock()
+}
+
+// WithLabelValues - gauge of the snapshot being built
+func (s *snapshotGaugeVec) WithLabelValues(lvs ...string) prometheus.Gauge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.building.WithLabelValues(lvs...)
+}
+
+// DeletePartialMatch - delete matching series from both the snapshot being built and the published one
+func (s *snapshotGaugeVec) DeletePartialMatch(labels prometheus.Labels) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.building.DeletePartialMatch(labels) + s.published.Load().DeletePartialMatch(labels)
+}
+
+// carryOver - replace the series matching labels in the snapshot being built by those of the
+// published snapshot, for a repository or organization whose Github API calls failed this cycle
+func (s *snapshotGaugeVec) carryOver(labels prometheus.Labels) {
+	// Read before locking mu, DeletePartialMatch waits for the published vec while holding it
+	ch := make(chan prometheus.Metric)
+	go func() {
+		s.published.Load().Collect(ch)
+		close(ch)
+	}()
+	var published []*dto.Metric
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err == nil {
+			published = append(published, &m)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.building.DeletePartialMatch(labels)
+	for _, m := range published {
+		values := map[string]string{}
+		for _, pair := range m.GetLabel() {
+			values[pair.GetName()] = pair.GetValue()
+		}
+		match := true
+		for name, value := range labels {
+			if values[name] != value {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		lvs := make([]string, len(s.labelNames))
+		for i, name := range s.labelNames {
+			lvs[i] = values[name]
+		}
+		s.building.WithLabelValues(lvs...).Set(m.GetGauge().GetValue())
+	}
+}
+
+// publish - serve the snapshot built since the last Reset
+func (s *snapshotGaugeVec) publish() {
+	s.mu.Lock()
+	s.published.Store(s.building)
+	s.building = prometheus.NewGaugeVec(s.opts, s.labelNames)
+	s.mu.Unlock()
+}
+
+// partialError - some repositories or organizations of a cycle failed. The snapshot is published
+// anyway, with the series of the failed ones carried over, and the collector is marked stale.
+type partialError struct {
+	err error
+}
+
+func (e partialError) Error() string {
+	return e.err.Error()
+}
+
+func (e partialError) Unwrap() error {
+	return e.err
+}
+
+// partial - a partialError joining errs, nil without errors
+func partial(errs []error) error {
+	if err := errors.Join(errs...); err != nil {
+		return partialError{err: err}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// served - series of the published snapshot, by comma separated label values
+func served(t *testing.T, s *snapshotGaugeVec) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		s.Collect(ch)
+		close(ch)
+	}()
+	result := map[string]float64{}
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		var values []string
+		for _, pair := range m.GetLabel() {
+			values = append(values, pair.GetValue())
+		}
+		result[strings.Join(values, ",")] = m.GetGauge().GetValue()
+	}
+	return result
+}
+
+func newTestGauge() *snapshotGaugeVec {
+	return newSnapshotGaugeVec(prometheus.GaugeOpts{Name: "github_test_gauge", Help: "test gauge"}, []string{"repo", "os"})
+}
+
+func TestSnapshotCarryOver(t *testing.T) {
+	gauge := newTestGauge()
+	gauge.WithLabelValues("foo/a", "linux").Set(1)
+	gauge.WithLabelValues("foo/b", "linux").Set(2)
+	gauge.WithLabelValues("foo/b", "windows").Set(3)
+	gauge.publish()
+
+	gauge.Reset()
+	gauge.WithLabelValues("foo/a", "linux").Set(10)
+	// Partially filled before failing, replaced by the published series
+	gauge.WithLabelValues("foo/b", "macos").Set(20)
+	gauge.carryOver(prometheus.Labels{"repo": "foo/b"})
+	// Nothing published for foo/c, nothing carried over
+	gauge.carryOver(prometheus.Labels{"repo": "foo/c"})
+	gauge.publish()
+
+	got := served(t, gauge)
+	want := map[string]float64{"linux,foo/a": 10, "linux,foo/b": 2, "windows,foo/b": 3}
+	if len(got) != len(want) {
+		t.Fatalf("served %v, want %v", got, want)
+	}
+	for series, value := range want {
+		if got[series] != value {
+			t.Errorf("%s = %v, want %v", series, got[series], value)
+		}
+	}
+}
+
+func TestCollectorRunPartial(t *testing.T) {
+	gauge := newTestGauge()
+	gauge.WithLabelValues("foo/a", "linux").Set(1)
+	gauge.WithLabelValues("foo/b", "linux").Set(1)
+	gauge.publish()
+
+	var cycleErr error
+	var notified []string
+	OnCycle(func(collector string) { notified = append(notified, collector) })
+	t.Cleanup(func() { cycleListeners.listeners = nil })
+	c := &collector{
+		name: "test",
+		collect: func(ctx context.Context) error {
+			if name := collectorName(ctx); name != "test" {
+				t.Errorf("collector name = %q, want test", name)
+			}
+			gauge.Reset()
+			gauge.WithLabelValues("foo/a", "linux").Set(2)
+			gauge.carryOver(prometheus.Labels{"repo": "foo/b"})
+			return cycleErr
+		},
+		gauges: func() []*snapshotGaugeVec { return []*snapshotGaugeVec{gauge} },
+	}
+
+	// Some repositories failed: published, failed ones carried over, stale
+	cycleErr = partial([]error{errors.New("ListRunners failed")})
+	if err := c.run(); err == nil {
+		t.Error("partial cycle returned no error")
+	}
+	if got := served(t, gauge); got["linux,foo/a"] != 2 || got["linux,foo/b"] != 1 {
+		t.Errorf("served %v after a partial cycle, want foo/a=2 and foo/b carried over", got)
+	}
+	if !c.stale || !c.lastSuccess.IsZero() {
+		t.Errorf("stale = %v, last success = %v, want stale without success", c.stale, c.lastSuccess)
+	}
+	if len(notified) != 1 {
+		t.Errorf("%d cycles notified after a partial cycle, want 1", len(notified))
+	}
+
+	// The whole cycle failed: the previous snapshot is kept
+	cycleErr = errors.New("RateLimits failed")
+	gauge.WithLabelValues("foo/a", "linux").Set(1)
+	c.collect = func(ctx context.Context) error {
+		gauge.Reset()
+		gauge.WithLabelValues("foo/a", "linux").Set(3)
+		return cycleErr
+	}
+	c.run()
+	if got := served(t, gauge); got["linux,foo/a"] != 2 {
+		t.Errorf("served %v after a failed cycle, want the previous snapshot", got)
+	}
+	if len(notified) != 1 {
+		t.Errorf("%d cycles notified after a failed cycle, want still 1", len(notified))
+	}
+
+	cycleErr = nil
+	start := time.Now()
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := served(t, gauge); len(got) != 1 || got["linux,foo/a"] != 3 {
+		t.Errorf("served %v after a complete cycle, want foo/a=3 only", got)
+	}
+	if c.stale || c.lastSuccess.Before(start) {
+		t.Errorf("stale = %v, last success = %v, want a fresh success", c.stale, c.lastSuccess)
+	}
+	if len(notified) != 2 || notified[1] != "test" {
+		t.Errorf("cycles notified = %v, want test twice", notified)
+	}
+}
@@ -0,0 +1,354 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+var (
+	// repoState - repositories monitored as of the last repositories refresh, the other collectors
+	// iterate on them
+	repoState struct {
+		sync.RWMutex
+		repos []string
+	}
+	// runnerState - runners seen by the last refresh of each runners collector
+	runnerState struct {
+		sync.RWMutex
+		repo         map[string][]*github.Runner
+		organization map[string][]*github.Runner
+		groups       map[string]map[int64]string
+		enterprise   []*github.Runner
+	}
+	// runState - workflow runs, with their jobs, seen by the last workflow runs refresh
+	runState struct {
+		sync.RWMutex
+		runs []observedRun
+	}
+	// rateLimitState - core rate limit from the last ratelimit refresh
+	rateLimitState struct {
+		sync.RWMutex
+		rate      github.Rate
+		checkedAt time.Time
+	}
+	// apiErrorLog - most recent failed Github API calls, oldest first
+	apiErrorLog struct {
+		sync.Mutex
+		errors []APIError
+	}
+)
+
+// apiErrorLogSize - number of failed Github API calls kept in apiErrorLog
+const apiErrorLogSize = 50
+
+// observedRun - a workflow run and its jobs, as fetched by getWorkflowRunsFromGithub
+type observedRun struct {
+	Repo string
+	Run  *github.WorkflowRun
+	Jobs []*workflowJob
+}
+
+// Runner - a self-hosted runner, as served by the API
+type Runner struct {
+	Scope       string   `json:"scope"`
+	Owner       string   `json:"owner"`
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	OS          string   `json:"os"`
+	Status      string   `json:"status"`
+	Busy        bool     `json:"busy"`
+	Labels      []string `json:"labels"`
+	RunnerGroup string   `json:"runner_group"`
+}
+
+// RateLimit - core rate limit budget, as of the last ratelimit refresh
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// APIError - a failed Github API call
+type APIError struct {
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	Message  string    `json:"message"`
+}
+
+// Run - a workflow run, as served by the API
+type Run struct {
+	Org        string     `json:"org"`
+	Repo       string     `json:"repo"`
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	WorkflowID int64      `json:"workflow_id"`
+	RunNumber  int        `json:"run_number"`
+	RunAttempt int        `json:"run_attempt"`
+	Event      string     `json:"event"`
+	HeadBranch string     `json:"head_branch"`
+	HeadSHA    string     `json:"head_sha"`
+	Status     string     `json:"status"`
+	Conclusion string     `json:"conclusion"`
+	HTMLURL    string     `json:"html_url"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"run_started_at"`
+	JobCount   int        `json:"job_count"`
+}
+
+// Job - a workflow job, as served by the API
+type Job struct {
+	Org          string     `json:"org"`
+	Repo         string     `json:"repo"`
+	RunID        int64      `json:"run_id"`
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	WorkflowName string     `json:"workflow_name"`
+	HeadBranch   string     `json:"head_branch"`
+	Status       string     `json:"status"`
+	Conclusion   string     `json:"conclusion"`
+	Labels       []string   `json:"labels"`
+	RunnerID     int64      `json:"runner_id"`
+	RunnerName   string     `json:"runner_name"`
+	RunnerGroup  string     `json:"runner_group"`
+	HTMLURL      string     `json:"html_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+func setRepositories(repos []string) {
+	repoState.Lock()
+	repoState.repos = repos
+	repoState.Unlock()
+}
+
+func setRepoRunners(runners map[string][]*github.Runner) {
+	runnerState.Lock()
+	runnerState.repo = runners
+	runnerState.Unlock()
+}
+
+func setOrganizationRunners(runners map[string][]*github.Runner, groups map[string]map[int64]string) {
+	runnerState.Lock()
+	runnerState.organization = runners
+	runnerState.groups = groups
+	runnerState.Unlock()
+}
+
+func setEnterpriseRunners(runners []*github.Runner) {
+	runnerState.Lock()
+	runnerState.enterprise = runners
+	runnerState.Unlock()
+}
+
+func setObservedRuns(runs []observedRun) {
+	runState.Lock()
+	runState.runs = runs
+	runState.Unlock()
+}
+
+func setRateLimit(rate github.Rate) {
+	rateLimitState.Lock()
+	rateLimitState.rate = rate
+	rateLimitState.checkedAt = time.Now()
+	rateLimitState.Unlock()
+}
+
+// recordAPIError - keep a failed call in apiErrorLog, dropping the oldest one when full
+func recordAPIError(endpoint string, message string) {
+	apiErrorLog.Lock()
+	defer apiErrorLog.Unlock()
+	apiErrorLog.errors = append(apiErrorLog.errors, APIError{Time: time.Now(), Endpoint: endpoint, Message: message})
+	if len(apiErrorLog.errors) > apiErrorLogSize {
+		apiErrorLog.errors = apiErrorLog.errors[len(apiErrorLog.errors)-apiErrorLogSize:]
+	}
+}
+
+// GetRateLimit - core rate limit budget from the last ratelimit refresh, zero before the first one
+func GetRateLimit() RateLimit {
+	rateLimitState.RLock()
+	defer rateLimitState.RUnlock()
+	return RateLimit{
+		Limit:     rateLimitState.rate.Limit,
+		Remaining: rateLimitState.rate.Remaining,
+		Reset:     rateLimitState.rate.Reset.Time,
+		CheckedAt: rateLimitState.checkedAt,
+	}
+}
+
+// RecentAPIErrors - most recent failed Github API calls, newest first
+func RecentAPIErrors() []APIError {
+	apiErrorLog.Lock()
+	defer apiErrorLog.Unlock()
+	result := make([]APIError, 0, len(apiErrorLog.errors))
+	for i := len(apiErrorLog.errors) - 1; i >= 0; i-- {
+		result = append(result, apiErrorLog.errors[i])
+	}
+	return result
+}
+
+// Repositories - repositories monitored as of the last repositories refresh
+func Repositories() []string {
+	repoState.RLock()
+	defer repoState.RUnlock()
+	return append([]string{}, repoState.repos...)
+}
+
+func newRunner(scope string, owner string, runner *github.Runner) Runner {
+	labels := make([]string, 0, len(runner.Labels))
+	for _, label := range runner.Labels {
+		labels = append(labels, label.GetName())
+	}
+	return Runner{
+		Scope:  scope,
+		Owner:  owner,
+		ID:     runner.GetID(),
+		Name:   runner.GetName(),
+		OS:     runner.GetOS(),
+		Status: runner.GetStatus(),
+		Busy:   runner.GetBusy(),
+		Labels: labels,
+	}
+}
+
+// Runners - repository, organization and enterprise runners from the last refreshes,
+// ordered by scope, owner and id
+func Runners() []Runner {
+	runnerState.RLock()
+	defer runnerState.RUnlock()
+
+	var result []Runner
+	for repo, runners := range runnerState.repo {
+		for _, runner := range runners {
+			result = append(result, newRunner("repo", repo, runner))
+		}
+	}
+	for orga, runners := range runnerState.organization {
+		for _, runner := range runners {
+			r := newRunner("organization", orga, runner)
+			r.RunnerGroup = runnerState.groups[orga][runner.GetID()]
+			result = append(result, r)
+		}
+	}
+	for _, runner := range runnerState.enterprise {
+		result = append(result, newRunner("enterprise", "", runner))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Scope != result[j].Scope {
+			return result[i].Scope < result[j].Scope
+		}
+		if result[i].Owner != result[j].Owner {
+			return result[i].Owner < result[j].Owner
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Runs - workflow runs from the last workflow runs refresh, newest first
+func Runs() []Run {
+	runState.RLock()
+	defer runState.RUnlock()
+
+	result := make([]Run, 0, len(runState.runs))
+	for _, o := range runState.runs {
+		run := o.Run
+		result = append(result, Run{
+			Org:        strings.Split(o.Repo, "/")[0],
+			Repo:       o.Repo,
+			ID:         run.GetID(),
+			Name:       run.GetName(),
+			WorkflowID: run.GetWorkflowID(),
+			RunNumber:  run.GetRunNumber(),
+			RunAttempt: run.GetRunAttempt(),
+			Event:      run.GetEvent(),
+			HeadBranch: run.GetHeadBranch(),
+			HeadSHA:    run.GetHeadSHA(),
+			Status:     run.GetStatus(),
+			Conclusion: run.GetConclusion(),
+			HTMLURL:    run.GetHTMLURL(),
+			CreatedAt:  run.GetCreatedAt().Time,
+			UpdatedAt:  run.GetUpdatedAt().Time,
+			StartedAt:  timePtr(run.RunStartedAt),
+			JobCount:   len(o.Jobs),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
+
+func newJob(repo string, run *github.WorkflowRun, job *workflowJob) Job {
+	createdAt := job.GetCreatedAt()
+	return Job{
+		Org:          strings.Split(repo, "/")[0],
+		Repo:         repo,
+		RunID:        job.GetRunID(),
+		ID:           job.GetID(),
+		Name:         job.GetName(),
+		WorkflowName: run.GetName(),
+		HeadBranch:   run.GetHeadBranch(),
+		Status:       job.GetStatus(),
+		Conclusion:   job.GetConclusion(),
+		Labels:       append([]string{}, job.Labels...),
+		RunnerID:     job.GetRunnerID(),
+		RunnerName:   job.GetRunnerName(),
+		RunnerGroup:  job.GetRunnerGroupName(),
+		HTMLURL:      job.GetHTMLURL(),
+		CreatedAt:    createdAt.Time,
+		StartedAt:    timePtr(job.StartedAt),
+		CompletedAt:  timePtr(job.CompletedAt),
+	}
+}
+
+// Jobs - jobs of the runs from the last workflow runs refresh, completed by the queued
+// and in progress jobs from the last job queue refresh, newest first.
+// A job seen by both is taken from the job queue, which is refreshed more often.
+func Jobs() []Job {
+	byID := map[int64]Job{}
+
+	runState.RLock()
+	for _, o := range runState.runs {
+		for _, job := range o.Jobs {
+			byID[job.GetID()] = newJob(o.Repo, o.Run, job)
+		}
+	}
+	runState.RUnlock()
+
+	activeJobs.RLock()
+	for _, j := range activeJobs.jobs {
+		byID[j.Job.GetID()] = newJob(j.Repo, j.Run, j.Job)
+	}
+	activeJobs.RUnlock()
+
+	result := make([]Job, 0, len(byID))
+	for _, job := range byID {
+		result = append(result, job)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
+
+func timePtr(t *github.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
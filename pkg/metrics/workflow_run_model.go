@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// trackedRun - a workflow run followed through its lifecycle by the workflow_runs collector
+type trackedRun struct {
+	repo string // <orga>/<repo>
+	run  *github.WorkflowRun
+	jobs []*workflowJob
+	// jobsUpdatedAt - updated_at of the run when its jobs were last fetched
+	jobsUpdatedAt time.Time
+	// usage - billable usage of the completed run, nil until fetched or when not available
+	usage *github.WorkflowRunUsage
+	// completedAttempt - last run attempt whose completion was handled
+	completedAttempt int
+}
+
+// runModel - tracked workflow runs, keyed by <orga>/<repo>/<run id>.
+// Only read and written by the workflow_runs collector.
+var runModel = map[string]*trackedRun{}
+
+func runKey(repo string, runId int64) string {
+	return repo + "/" + strconv.FormatInt(runId, 10)
+}
+
+// trackRun - add a run to the model or update it, logging status transitions
+func trackRun(repo string, run *github.WorkflowRun) *trackedRun {
+	key := runKey(repo, run.GetID())
+	t, ok := runModel[key]
+	if !ok {
+		t = &trackedRun{repo: repo}
+		runModel[key] = t
+		slog.Debug("workflow run tracked", runAttrs(repo, run, "status", run.GetStatus())...)
+	} else if t.run.GetStatus() != run.GetStatus() || t.run.GetRunAttempt() != run.GetRunAttempt() {
+		slog.Debug("workflow run status changed", runAttrs(repo, run, "from", t.run.GetStatus(), "to", run.GetStatus())...)
+	}
+	if t.run != nil && t.run.GetRunAttempt() != run.GetRunAttempt() {
+		// A re-run, its usage is fetched again once completed
+		t.usage = nil
+	}
+	t.run = run
+	return t
+}
+
+// jobsOutdated - true if the jobs of the run may have changed since they were last fetched
+func (t *trackedRun) jobsOutdated() bool {
+	return t.run.GetStatus() != "completed" || !t.jobsUpdatedAt.Equal(t.run.GetUpdatedAt().Time)
+}
+
+// setJobs - replace the jobs of the run, logging status transitions and accounting jobs that completed
+func (t *trackedRun) setJobs(jobs []*workflowJob) {
+	r := strings.Split(t.repo, "/")
+	previous := map[int64]string{}
+	for _, job := range t.jobs {
+		previous[job.GetID()] = job.GetStatus()
+	}
+
+	for _, job := range jobs {
+		status, ok := previous[job.GetID()]
+		if ok && status == job.GetStatus() {
+			continue
+		}
+		slog.Debug("job status changed", runAttrs(t.repo, t.run, "job_id", job.GetID(), "job", job.GetName(), "from", status, "to", job.GetStatus())...)
+		if job.GetStatus() == "completed" {
+			jobSeconds := math.Max(0, job.GetCompletedAt().Time.Sub(job.GetStartedAt().Time).Seconds())
+			observeJobDuration(r[0], r[1], t.run, job, jobSeconds)
+			recordRunnerUtilization(r[0], r[1], job)
+		}
+	}
+	t.jobs = jobs
+	t.jobsUpdatedAt = t.run.GetUpdatedAt().Time
+}
+
+// complete - account a run that completed, once per attempt and once its jobs are up to date
+func (t *trackedRun) complete(ctx context.Context) {
+	if t.run.GetStatus() != "completed" || t.completedAttempt == t.run.GetRunAttempt() || t.jobsOutdated() {
+		return
+	}
+	r := strings.Split(t.repo, "/")
+	if config.Metrics.FetchWorkflowRunUsage {
+		t.usage = getRunUsage(ctx, r[0], r[1], t.run.GetID())
+	}
+	observeRunDuration(r[0], r[1], t.run, t.durationMs()/1000)
+	exportRunTrace(r[0], r[1], t.run, t.jobs)
+	t.completedAttempt = t.run.GetRunAttempt()
+}
+
+// durationMs - billable duration of the run if known, otherwise the time between its creation and last update.
+// The latter is also the fallback for Github Enterprise.
+func (t *trackedRun) durationMs() float64 {
+	if t.usage != nil {
+		return float64(t.usage.GetRunDurationMS())
+	}
+	return float64(t.run.GetUpdatedAt().Time.Sub(t.run.GetCreatedAt().Time).Milliseconds())
+}
+
+// expired - true once a completed run is past the retention at now, or its repository is not monitored anymore
+func (t *trackedRun) expired(monitored map[string]bool, now time.Time) bool {
+	if !monitored[t.repo] {
+		return true
+	}
+	retention := time.Duration(config.Metrics.WorkflowRunRetention) * time.Second
+	return t.run.GetStatus() == "completed" && now.Sub(t.run.GetUpdatedAt().Time) > retention
+}
+
+func runAttrs(repo string, run *github.WorkflowRun, args ...any) []any {
+	r := strings.Split(repo, "/")
+	return append([]any{"collector", "workflow_runs", "org", r[0], "repo", r[1], "run_id", run.GetID()}, args...)
+}
+
+// runConclusionValue - value of github_workflow_run_status for a run conclusion
+func runConclusionValue(conclusion string) float64 {
+	switch conclusion {
+	case "success":
+		return 1
+	case "skipped":
+		return 2
+	case "action_required":
+		return 3
+	case "cancelled":
+		return 4
+	case "failure":
+		return 5
+	case "neutral":
+		return 6
+	case "stale":
+		return 7
+	case "timed_out":
+		return 8
+	}
+	return 0
+}
+
+// jobConclusionValue - value of github_workflow_job_status_count for a job conclusion
+func jobConclusionValue(conclusion string) float64 {
+	switch conclusion {
+	case "success":
+		return 1
+	case "failure":
+		return 2
+	case "cancelled":
+		return 3
+	case "skipped":
+		return 4
+	case "timed_out":
+		return 5
+	case "action_required":
+		return 6
+	case "neutral":
+		return 7
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// modelStart - fixed time the runs of the model tests start at
+var modelStart = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func modelTime(minutes int) *github.Timestamp {
+	return &github.Timestamp{Time: modelStart.Add(time.Duration(minutes) * time.Minute)}
+}
+
+func modelRun(status string, attempt int, updated int) *github.WorkflowRun {
+	return &github.WorkflowRun{
+		ID: github.Int64(42), Name: github.String("ci"), RunAttempt: github.Int(attempt),
+		Status: github.String(status), CreatedAt: modelTime(0), UpdatedAt: modelTime(updated),
+	}
+}
+
+func modelJob(id int64, status string, completed int) *workflowJob {
+	job := &github.WorkflowJob{
+		ID: github.Int64(id), Name: github.String("build"), Status: github.String(status),
+		RunnerID: github.Int64(9), RunnerName: github.String("runner-9"), RunnerGroupName: github.String("default"),
+		StartedAt: modelTime(1),
+	}
+	if status == "completed" {
+		job.Conclusion = github.String("success")
+		job.CompletedAt = modelTime(completed)
+	}
+	return &workflowJob{WorkflowJob: job, CreatedAt: modelTime(0)}
+}
+
+func setupRunModel(t *testing.T) {
+	t.Helper()
+	previousCache := cache
+	cache = freecache.NewCache(1024 * 1024)
+	runModel = map[string]*trackedRun{}
+	t.Cleanup(func() {
+		cache = previousCache
+		runModel = map[string]*trackedRun{}
+	})
+}
+
+func TestTrackRun(t *testing.T) {
+	setupRunModel(t)
+
+	tracked := trackRun("foo/svc", modelRun("in_progress", 1, 1))
+	if again := trackRun("foo/svc", modelRun("completed", 1, 5)); again != tracked || len(runModel) != 1 {
+		t.Fatalf("run tracked twice: %d runs", len(runModel))
+	}
+	if tracked.run.GetStatus() != "completed" {
+		t.Errorf("status = %s, want the last one seen", tracked.run.GetStatus())
+	}
+
+	// A re-run gets its usage fetched again
+	tracked.usage = &github.WorkflowRunUsage{RunDurationMS: github.Int64(1000)}
+	trackRun("foo/svc", modelRun("completed", 1, 5))
+	if tracked.usage == nil {
+		t.Error("usage dropped without a new attempt")
+	}
+	trackRun("foo/svc", modelRun("queued", 2, 10))
+	if tracked.usage != nil {
+		t.Error("usage of the previous attempt kept for a re-run")
+	}
+}
+
+func TestRunJobsInProgress(t *testing.T) {
+	setupRunModel(t)
+	jobs := func() float64 {
+		return testutil.ToFloat64(runnerJobsCounter.WithLabelValues("9", "runner-9", "default"))
+	}
+	before := jobs()
+
+	tracked := trackRun("foo/svc", modelRun("in_progress", 1, 2))
+	tracked.setJobs([]*workflowJob{modelJob(1, "queued", 0), modelJob(2, "in_progress", 0)})
+	if !tracked.jobsOutdated() {
+		t.Error("jobs of a run in progress not outdated")
+	}
+	tracked.complete(context.Background())
+	if tracked.completedAttempt != 0 {
+		t.Error("run in progress completed")
+	}
+
+	// Job 1 completed while the run is still in progress, accounted once
+	tracked.setJobs([]*workflowJob{modelJob(1, "completed", 3), modelJob(2, "in_progress", 0)})
+	tracked.setJobs([]*workflowJob{modelJob(1, "completed", 3), modelJob(2, "in_progress", 0)})
+	if got := jobs() - before; got != 1 {
+		t.Errorf("%v completed jobs accounted, want 1", got)
+	}
+	if len(tracked.jobs) != 2 || tracked.jobs[0].GetStatus() != "completed" || tracked.jobs[1].GetStatus() != "in_progress" {
+		t.Errorf("jobs not updated: %v", tracked.jobs)
+	}
+
+	// The run completed, its jobs are outdated until fetched again
+	trackRun("foo/svc", modelRun("completed", 1, 6))
+	if !tracked.jobsOutdated() {
+		t.Error("jobs fetched before the run completed not outdated")
+	}
+	tracked.complete(context.Background())
+	if tracked.completedAttempt != 0 {
+		t.Error("run completed with outdated jobs")
+	}
+	tracked.setJobs([]*workflowJob{modelJob(1, "completed", 3), modelJob(2, "completed", 5)})
+	if tracked.jobsOutdated() {
+		t.Error("jobs of a completed run outdated once fetched")
+	}
+	tracked.complete(context.Background())
+	if tracked.completedAttempt != 1 {
+		t.Errorf("completed attempt = %d, want 1", tracked.completedAttempt)
+	}
+	if got := jobs() - before; got != 2 {
+		t.Errorf("%v completed jobs accounted, want 2", got)
+	}
+	if got := tracked.durationMs(); got != 6*60*1000 {
+		t.Errorf("duration = %vms, want from creation to the last update", got)
+	}
+}
+
+func TestRunExpired(t *testing.T) {
+	defer func(retention int64) { config.Metrics.WorkflowRunRetention = retention }(config.Metrics.WorkflowRunRetention)
+	config.Metrics.WorkflowRunRetention = 3600
+	monitored := map[string]bool{"foo/svc": true}
+
+	for _, tc := range []struct {
+		name    string
+		repo    string
+		status  string
+		updated int
+		// now - minutes after modelStart
+		now  int
+		want bool
+	}{
+		{"completed within the retention", "foo/svc", "completed", 10, 60, false},
+		{"completed at the retention", "foo/svc", "completed", 10, 70, false},
+		{"completed past the retention", "foo/svc", "completed", 10, 71, true},
+		{"in progress past the retention", "foo/svc", "in_progress", 10, 600, false},
+		{"queued past the retention", "foo/svc", "queued", 0, 600, false},
+		{"repository no longer monitored", "foo/other", "in_progress", 10, 11, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracked := &trackedRun{repo: tc.repo, run: modelRun(tc.status, 1, tc.updated)}
+			if got := tracked.expired(monitored, modelTime(tc.now).Time); got != tc.want {
+				t.Errorf("expired = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
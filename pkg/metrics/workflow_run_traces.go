@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/otlp"
+)
+
+// setSpanConclusion - mark the span as failed for failed, timed out and cancelled runs / jobs / steps
+func setSpanConclusion(span trace.Span, conclusion string) {
+	span.SetAttributes(attribute.String("github.conclusion", conclusion))
+	switch conclusion {
+	case "failure", "timed_out", "cancelled", "startup_failure":
+		span.SetStatus(codes.Error, conclusion)
+	case "success":
+		span.SetStatus(codes.Ok, "")
+	}
+}
+
+// exportRunTrace - export a completed workflow run as a trace: one span for the run, a child span
+// per job (with its queue time as a child span) and a grandchild span per step.
+// Ids are derived from the run id and attempt, a run attempt is exported once.
+func exportRunTrace(owner string, repo string, run *github.WorkflowRun, jobs []*workflowJob) {
+	if !config.OTLP.Traces || run.GetStatus() != "completed" {
+		return
+	}
+	cacheKey := "run-trace:" + owner + "/" + repo + "/" + strconv.FormatInt(run.GetID(), 10) + "/" + strconv.Itoa(run.GetRunAttempt())
+	if getCache(cacheKey) != nil {
+		return
+	}
+
+	tracer := otel.Tracer("github.com/chipgata/github-actions-exporter")
+	traceID := otlp.RunTraceID(run.GetID(), run.GetRunAttempt())
+
+	runStart := run.GetRunStartedAt().Time
+	if runStart.IsZero() {
+		runStart = run.GetCreatedAt().Time
+	}
+	runCtx, runSpan := tracer.Start(otlp.WithSpanID(context.Background(), traceID, "run"), run.GetName(),
+		trace.WithTimestamp(runStart),
+		trace.WithAttributes(
+			attribute.String("github.repository", owner+"/"+repo),
+			attribute.Int64("github.run_id", run.GetID()),
+			attribute.Int("github.run_attempt", run.GetRunAttempt()),
+			attribute.Int("github.run_number", run.GetRunNumber()),
+			attribute.String("github.event", run.GetEvent()),
+			attribute.String("github.head_branch", run.GetHeadBranch()),
+			attribute.String("github.head_sha", run.GetHeadSHA()),
+			attribute.String("github.url", run.GetHTMLURL()),
+		),
+	)
+	setSpanConclusion(runSpan, run.GetConclusion())
+
+	for _, job := range jobs {
+		if job.GetStatus() != "completed" {
+			continue
+		}
+		jobId := strconv.FormatInt(job.GetID(), 10)
+		queuedAt := job.GetCreatedAt().Time
+		startedAt := job.GetStartedAt().Time
+		if queuedAt.After(startedAt) {
+			queuedAt = startedAt
+		}
+
+		jobCtx, jobSpan := tracer.Start(otlp.WithSpanID(runCtx, traceID, "job:"+jobId), job.GetName(),
+			trace.WithTimestamp(queuedAt),
+			trace.WithAttributes(
+				attribute.Int64("github.job_id", job.GetID()),
+				attribute.String("github.runner_name", job.GetRunnerName()),
+				attribute.String("github.runner_group", job.GetRunnerGroupName()),
+				attribute.String("github.runner_labels", getRunnerLabelString(job.Labels)),
+				attribute.String("github.url", job.GetHTMLURL()),
+			),
+		)
+		setSpanConclusion(jobSpan, job.GetConclusion())
+
+		_, queueSpan := tracer.Start(otlp.WithSpanID(jobCtx, traceID, "queue:"+jobId), "queued",
+			trace.WithTimestamp(queuedAt),
+		)
+		queueSpan.End(trace.WithTimestamp(startedAt))
+
+		for _, step := range job.Steps {
+			if step.StartedAt == nil || step.CompletedAt == nil {
+				continue
+			}
+			_, stepSpan := tracer.Start(otlp.WithSpanID(jobCtx, traceID, "step:"+jobId+":"+strconv.FormatInt(step.GetNumber(), 10)), step.GetName(),
+				trace.WithTimestamp(step.GetStartedAt().Time),
+				trace.WithAttributes(attribute.Int64("github.step_number", step.GetNumber())),
+			)
+			setSpanConclusion(stepSpan, step.GetConclusion())
+			stepSpan.End(trace.WithTimestamp(step.GetCompletedAt().Time))
+		}
+
+		jobSpan.End(trace.WithTimestamp(latest(startedAt, job.GetCompletedAt().Time)))
+	}
+
+	runSpan.End(trace.WithTimestamp(latest(runStart, run.GetUpdatedAt().Time)))
+	setCache(cacheKey, []byte("1"), 7200)
+}
+
+func latest(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
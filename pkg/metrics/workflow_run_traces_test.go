@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/google/go-github/v45/github"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/otlp"
+)
+
+// traceRun - export the run with a new tracer provider, and return the spans it exported by name
+func traceRun(t *testing.T, run *github.WorkflowRun, jobs []*workflowJob, times int) map[string]tracetest.SpanStub {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := otlp.NewTracerProvider(exporter, "test")
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	for i := 0; i < times; i++ {
+		exportRunTrace("foo", "svc", run, jobs)
+	}
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		if _, ok := spans[span.Name]; ok {
+			t.Errorf("span %s exported twice", span.Name)
+		}
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestExportRunTrace(t *testing.T) {
+	config.OTLP.Traces = true
+	previousCache := cache
+	t.Cleanup(func() {
+		config.OTLP.Traces = false
+		cache = previousCache
+	})
+
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) *github.Timestamp {
+		return &github.Timestamp{Time: start.Add(time.Duration(seconds) * time.Second)}
+	}
+	run := &github.WorkflowRun{
+		ID: github.Int64(42), RunAttempt: github.Int(1), Name: github.String("ci"),
+		Status: github.String("completed"), Conclusion: github.String("failure"),
+		CreatedAt: at(0), RunStartedAt: at(0), UpdatedAt: at(120),
+	}
+	jobs := []*workflowJob{{
+		WorkflowJob: &github.WorkflowJob{
+			ID: github.Int64(7), Name: github.String("build"), Status: github.String("completed"), Conclusion: github.String("failure"),
+			StartedAt: at(30), CompletedAt: at(110),
+			Steps: []*github.TaskStep{
+				{Name: github.String("checkout"), Number: github.Int64(1), Conclusion: github.String("success"), StartedAt: at(30), CompletedAt: at(40)},
+				{Name: github.String("test"), Number: github.Int64(2), Conclusion: github.String("failure"), StartedAt: at(40), CompletedAt: at(110)},
+			},
+		},
+		CreatedAt: at(5),
+	}}
+
+	// Fetched on two refreshes, exported once
+	cache = freecache.NewCache(1024 * 1024)
+	spans := traceRun(t, run, jobs, 2)
+	if len(spans) != 5 {
+		t.Fatalf("exported spans %v, want ci, build, queued, checkout and test", spans)
+	}
+
+	traceID := otlp.RunTraceID(42, 1)
+	for name, span := range spans {
+		if span.SpanContext.TraceID() != traceID {
+			t.Errorf("span %s trace id = %s, want %s", name, span.SpanContext.TraceID(), traceID)
+		}
+	}
+	if spans["ci"].Parent.IsValid() {
+		t.Errorf("run span parent = %s, want none", spans["ci"].Parent.SpanID())
+	}
+	for name, parent := range map[string]string{"build": "ci", "queued": "build", "checkout": "build", "test": "build"} {
+		if got, want := spans[name].Parent.SpanID(), spans[parent].SpanContext.SpanID(); got != want {
+			t.Errorf("span %s parent = %s, want %s (%s)", name, got, want, parent)
+		}
+	}
+	if queued := spans["queued"]; !queued.StartTime.Equal(at(5).Time) || !queued.EndTime.Equal(at(30).Time) {
+		t.Errorf("queued span from %s to %s, want from the job creation to its start", queued.StartTime, queued.EndTime)
+	}
+
+	// Exported again after a restart (empty cache), with the same ids
+	cache = freecache.NewCache(1024 * 1024)
+	again := traceRun(t, run, jobs, 1)
+	for name, span := range spans {
+		if again[name].SpanContext.SpanID() != span.SpanContext.SpanID() || again[name].Parent.SpanID() != span.Parent.SpanID() {
+			t.Errorf("span %s ids changed when exported again", name)
+		}
+	}
+
+	// Another attempt of the run is another trace
+	cache = freecache.NewCache(1024 * 1024)
+	rerun := *run
+	rerun.RunAttempt = github.Int(2)
+	retried := traceRun(t, &rerun, jobs, 1)
+	if retried["ci"].SpanContext.TraceID() == traceID || retried["ci"].SpanContext.TraceID() != otlp.RunTraceID(42, 2) {
+		t.Errorf("second attempt trace id = %s, want %s", retried["ci"].SpanContext.TraceID(), otlp.RunTraceID(42, 2))
+	}
+}
@@ -0,0 +1,75 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// getResource - resource attributes identifying the exporter and the GitHub host / organizations it watches
+func getResource(version string) *resource.Resource {
+	return resource.NewSchemaless(
+		attribute.String("service.name", "github-actions-exporter"),
+		attribute.String("service.version", version),
+		attribute.String("github.host", config.Github.APIURL),
+		attribute.StringSlice("github.org", config.Organizations()),
+	)
+}
+
+// setErrorHandler - log the export failures of the OTLP exporters, reported through the
+// global otel error handler
+func setErrorHandler() {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Error("otlp export failed", "protocol", config.OTLP.Protocol, "endpoint", config.OTLP.Endpoint, "error", err)
+	}))
+}
+
+func newMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	switch config.OTLP.Protocol {
+	case "grpc":
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(config.OTLP.Endpoint)}
+		if config.OTLP.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	case "http/protobuf":
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(config.OTLP.Endpoint)}
+		if config.OTLP.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unknown otlp protocol %q, must be grpc or http/protobuf", config.OTLP.Protocol)
+}
+
+// RunMetricsExporter - push the metrics registered in the prometheus default registry over OTLP.
+// The returned func flushes and stops the exporter.
+func RunMetricsExporter(ctx context.Context, version string) (func(context.Context) error, error) {
+	exporter, err := newMetricExporter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("otlp metric exporter creation failed: %v", err)
+	}
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(time.Duration(config.OTLP.Interval)*time.Second),
+		sdkmetric.WithProducer(promBridge.NewMetricProducer()),
+	)
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(getResource(version)),
+	)
+
+	setErrorHandler()
+	slog.Info("pushing metrics over otlp", "protocol", config.OTLP.Protocol, "endpoint", config.OTLP.Endpoint)
+	return provider.Shutdown, nil
+}
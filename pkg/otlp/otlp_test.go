@@ -0,0 +1,168 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// receiver - OTLP metrics receiver keeping the export requests it got
+type receiver struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	requests []*collectormetrics.ExportMetricsServiceRequest
+}
+
+func (r *receiver) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+// ServeHTTP - OTLP/HTTP with protobuf payloads
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/v1/metrics" || req.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var export collectormetrics.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, _ := r.Export(req.Context(), &export)
+	data, _ := proto.Marshal(resp)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(data)
+}
+
+var registerTestGauge sync.Once
+
+func setup(t *testing.T, protocol string, endpoint string) {
+	t.Helper()
+	registerTestGauge.Do(func() {
+		gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "github_otlp_test_gauge", Help: "test gauge"}, []string{"repo"})
+		gauge.WithLabelValues("foo/svc").Set(42)
+		prometheus.MustRegister(gauge)
+	})
+	config.OTLP.Protocol = protocol
+	config.OTLP.Endpoint = endpoint
+	config.OTLP.Insecure = true
+	config.OTLP.Interval = 3600
+	config.Github.APIURL = "https://ghes.example.com/"
+	config.Apply(config.Reloadable{Organizations: []string{"foo", "bar"}})
+}
+
+// export - run the exporter, the metrics are pushed when it is stopped
+func export(t *testing.T) {
+	t.Helper()
+	shutdown, err := RunMetricsExporter(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("RunMetricsExporter: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func checkReceived(t *testing.T, r *receiver) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		t.Fatal("no export request received")
+	}
+
+	var found bool
+	for _, req := range r.requests {
+		for _, rm := range req.GetResourceMetrics() {
+			attrs := map[string]string{}
+			for _, kv := range rm.GetResource().GetAttributes() {
+				if values := kv.GetValue().GetArrayValue().GetValues(); values != nil {
+					var s []string
+					for _, v := range values {
+						s = append(s, v.GetStringValue())
+					}
+					attrs[kv.GetKey()] = strings.Join(s, ",")
+				} else {
+					attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+				}
+			}
+			if attrs["github.host"] != "https://ghes.example.com/" || attrs["github.org"] != "foo,bar" || attrs["service.name"] != "github-actions-exporter" {
+				t.Errorf("resource attributes = %v", attrs)
+			}
+
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					if m.GetName() != "github_otlp_test_gauge" {
+						continue
+					}
+					for _, dp := range m.GetGauge().GetDataPoints() {
+						found = true
+						if dp.GetAsDouble() != 42 {
+							t.Errorf("github_otlp_test_gauge = %v, want 42", dp.GetAsDouble())
+						}
+						if len(dp.GetAttributes()) != 1 || dp.GetAttributes()[0].GetKey() != "repo" || dp.GetAttributes()[0].GetValue().GetStringValue() != "foo/svc" {
+							t.Errorf("github_otlp_test_gauge attributes = %v", dp.GetAttributes())
+						}
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		t.Error("github_otlp_test_gauge not received")
+	}
+}
+
+func TestRunMetricsExporterGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &receiver{}
+	server := grpc.NewServer()
+	collectormetrics.RegisterMetricsServiceServer(server, r)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	setup(t, "grpc", listener.Addr().String())
+	export(t)
+	checkReceived(t, r)
+}
+
+func TestRunMetricsExporterHTTP(t *testing.T) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	setup(t, "http/protobuf", strings.TrimPrefix(server.URL, "http://"))
+	export(t)
+	checkReceived(t, r)
+}
+
+func TestRunMetricsExporterUnknownProtocol(t *testing.T) {
+	setup(t, "http/json", "localhost:4318")
+	if _, err := RunMetricsExporter(context.Background(), "test"); err == nil || !strings.Contains(err.Error(), "unknown otlp protocol") {
+		t.Errorf("RunMetricsExporter error = %v, want unknown otlp protocol", err)
+	}
+}
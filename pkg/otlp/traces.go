@@ -0,0 +1,111 @@
+package otlp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+type spanIDKey struct{}
+
+// spanIDs - ids the next span started with the context must get
+type spanIDs struct {
+	traceID trace.TraceID
+	spanID  trace.SpanID
+}
+
+// idGenerator - take trace and span ids from the context, so that a workflow run
+// always gives the same trace. Falls back on random ids.
+type idGenerator struct{}
+
+func (idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if ids, ok := ctx.Value(spanIDKey{}).(spanIDs); ok {
+		return ids.traceID, ids.spanID
+	}
+	var traceID trace.TraceID
+	_, _ = rand.Read(traceID[:])
+	return traceID, randomSpanID()
+}
+
+func (idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	if ids, ok := ctx.Value(spanIDKey{}).(spanIDs); ok && ids.traceID == traceID {
+		return ids.spanID
+	}
+	return randomSpanID()
+}
+
+func randomSpanID() trace.SpanID {
+	var spanID trace.SpanID
+	_, _ = rand.Read(spanID[:])
+	return spanID
+}
+
+// RunTraceID - trace id of a workflow run attempt
+func RunTraceID(runId int64, attempt int) trace.TraceID {
+	var traceID trace.TraceID
+	sum := sha256.Sum256([]byte("run:" + strconv.FormatInt(runId, 10) + ":" + strconv.Itoa(attempt)))
+	copy(traceID[:], sum[:])
+	return traceID
+}
+
+// WithSpanID - context for starting a span with an id derived from the trace id and a key (e.g. "job:123")
+func WithSpanID(ctx context.Context, traceID trace.TraceID, key string) context.Context {
+	var spanID trace.SpanID
+	sum := sha256.Sum256(append(traceID[:], []byte(key)...))
+	copy(spanID[:], sum[:])
+	return context.WithValue(ctx, spanIDKey{}, spanIDs{traceID: traceID, spanID: spanID})
+}
+
+func newTraceExporter(ctx context.Context) (*otlptrace.Exporter, error) {
+	switch config.OTLP.Protocol {
+	case "grpc":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.OTLP.Endpoint)}
+		if config.OTLP.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "http/protobuf":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.OTLP.Endpoint)}
+		if config.OTLP.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unknown otlp protocol %q, must be grpc or http/protobuf", config.OTLP.Protocol)
+}
+
+// NewTracerProvider - tracer provider batching spans to exporter, with the ids of RunTraceID and WithSpanID
+func NewTracerProvider(exporter sdktrace.SpanExporter, version string) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(getResource(version)),
+		sdktrace.WithIDGenerator(idGenerator{}),
+	)
+}
+
+// RunTraceExporter - install a global tracer provider exporting spans over OTLP.
+// The returned func flushes and stops the exporter.
+func RunTraceExporter(ctx context.Context, version string) (func(context.Context) error, error) {
+	exporter, err := newTraceExporter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("otlp trace exporter creation failed: %v", err)
+	}
+	provider := NewTracerProvider(exporter, version)
+	otel.SetTracerProvider(provider)
+
+	setErrorHandler()
+	slog.Info("exporting workflow run traces over otlp", "protocol", config.OTLP.Protocol, "endpoint", config.OTLP.Endpoint)
+	return provider.Shutdown, nil
+}
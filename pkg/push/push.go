@@ -0,0 +1,120 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+// retryBackoff - wait before the first retry, doubled on each retry
+var retryBackoff = time.Second
+
+// permanentError - a push failure that retrying won't fix, e.g. a 4xx from the receiver
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// checkStatus - error for a non 2xx response, permanent for a 4xx other than 429 as the remote
+// write spec asks not to retry those
+func checkStatus(name string, statusCode int, status string, body []byte) error {
+	if statusCode/100 == 2 {
+		return nil
+	}
+	err := fmt.Errorf("%s returned %s: %s", name, status, strings.TrimSpace(string(body)))
+	if statusCode/100 == 4 && statusCode != http.StatusTooManyRequests {
+		return permanentError{err: err}
+	}
+	return err
+}
+
+// withRetry - call f until it succeeds or config.Push.Retries retries are exhausted,
+// with an exponential backoff (1s, 2s, 4s, ...) and jitter between attempts.
+// A permanentError is returned right away.
+func withRetry(name string, endpoint string, f func() error) error {
+	var err error
+	for attempt := 0; attempt <= config.Push.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<uint(attempt-1)) * retryBackoff
+			backoff += time.Duration(rand.Int63n(int64(backoff) / 2))
+			slog.Warn("push failed, retrying", "output", name, "endpoint", endpoint, "attempt", attempt, "wait", backoff.String(), "error", err)
+			time.Sleep(backoff)
+		}
+		if err = f(); err == nil {
+			return nil
+		}
+		if errors.As(err, &permanentError{}) {
+			return fmt.Errorf("%s failed, not retried: %v", name, err)
+		}
+	}
+	return fmt.Errorf("%s failed after %d retries: %v", name, config.Push.Retries, err)
+}
+
+// parseLabels - <label>=<value> pairs of a flag
+func parseLabels(flag string, pairs []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid %s %q, format is <label>=<value>", flag, pair)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+// pushTo - push with retries, logging the outcome
+func pushTo(name string, endpoint string, f func() error) {
+	start := time.Now()
+	if err := withRetry(name, endpoint, f); err != nil {
+		slog.Error("push failed, giving up", "output", name, "endpoint", endpoint, "duration", time.Since(start).String(), "error", err)
+		return
+	}
+	slog.Debug("push done", "output", name, "endpoint", endpoint, "duration", time.Since(start).String())
+}
+
+// RunPusher - push the metrics to the configured remote write url and / or pushgateway each time
+// a collector publishes a cycle, from a new goroutine. Cycles published while a push is in progress
+// are pushed once, right after it. Call it before starting the collectors not to miss a cycle.
+func RunPusher() {
+	if config.Push.RemoteWriteURL != "" {
+		slog.Info("pushing metrics with remote write", "endpoint", config.Push.RemoteWriteURL)
+	}
+	if config.Push.PushgatewayURL != "" {
+		slog.Info("pushing metrics to pushgateway", "endpoint", config.Push.PushgatewayURL)
+	}
+	cycles := make(chan struct{}, 1)
+	metrics.OnCycle(func(collector string) {
+		select {
+		case cycles <- struct{}{}:
+		default:
+		}
+	})
+	go pushCycles(cycles)
+}
+
+// pushCycles - push once for each notification of cycles, until it is closed
+func pushCycles(cycles <-chan struct{}) {
+	for range cycles {
+		if config.Push.RemoteWriteURL != "" {
+			pushTo("remote write", config.Push.RemoteWriteURL, remoteWrite)
+		}
+		if config.Push.PushgatewayURL != "" {
+			pushTo("pushgateway push", config.Push.PushgatewayURL, pushgatewayPush)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package push
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+func TestWithRetry(t *testing.T) {
+	setupMetrics()
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = time.Second }()
+
+	for _, tc := range []struct {
+		name     string
+		status   []int
+		retries  int
+		requests int
+		ok       bool
+	}{
+		{"5xx then success", []int{http.StatusServiceUnavailable, http.StatusInternalServerError}, 3, 3, true},
+		{"429 is retried", []int{http.StatusTooManyRequests}, 3, 2, true},
+		{"5xx until retries are exhausted", []int{502, 502, 502, 502}, 2, 3, false},
+		{"4xx is not retried", []int{http.StatusBadRequest}, 3, 1, false},
+		{"401 is not retried", []int{http.StatusUnauthorized}, 3, 1, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resetPushConfig()
+			receiver := &writeReceiver{status: tc.status}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			config.Push.RemoteWriteURL = server.URL
+			config.Push.Retries = tc.retries
+
+			start := time.Now()
+			err := withRetry("remote write", server.URL, remoteWrite)
+			if (err == nil) != tc.ok {
+				t.Errorf("withRetry error = %v, want success %v", err, tc.ok)
+			}
+			if receiver.requests != tc.requests {
+				t.Errorf("%d requests, want %d", receiver.requests, tc.requests)
+			}
+			// 1ms, 2ms, 4ms, ... with up to 50% jitter
+			var minWait time.Duration
+			for i := 0; i < tc.requests-1; i++ {
+				minWait += time.Duration(1<<uint(i)) * retryBackoff
+			}
+			if elapsed := time.Since(start); elapsed < minWait {
+				t.Errorf("retried in %s, want a backoff of at least %s", elapsed, minWait)
+			}
+			if !tc.ok && tc.requests == 1 && !strings.Contains(err.Error(), "not retried") {
+				t.Errorf("withRetry error = %v, want not retried", err)
+			}
+		})
+	}
+}
+
+func TestPushCycles(t *testing.T) {
+	setupMetrics()
+	resetPushConfig()
+	receiver := &writeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	config.Push.RemoteWriteURL = server.URL
+
+	// No cycle published, nothing pushed
+	cycles := make(chan struct{}, 2)
+	close(cycles)
+	pushCycles(cycles)
+	if receiver.requests != 0 {
+		t.Errorf("%d pushes without a cycle, want 0", receiver.requests)
+	}
+
+	cycles = make(chan struct{}, 2)
+	cycles <- struct{}{}
+	cycles <- struct{}{}
+	close(cycles)
+	pushCycles(cycles)
+	if receiver.requests != 2 {
+		t.Errorf("%d pushes for 2 cycles, want 2", receiver.requests)
+	}
+}
@@ -0,0 +1,51 @@
+package push
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// statusRecorder - http client of the pusher keeping the status code of the last response,
+// the pusher only returns it in an error message
+type statusRecorder struct {
+	client     *http.Client
+	statusCode int
+}
+
+func (r *statusRecorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err == nil {
+		r.statusCode = resp.StatusCode
+	}
+	return resp, err
+}
+
+// pushgatewayPush - replace the metrics of the job / grouping key on the pushgateway
+func pushgatewayPush() error {
+	recorder := &statusRecorder{client: pushClient}
+	pusher := push.New(config.Push.PushgatewayURL, config.Push.PushgatewayJob).Gatherer(prometheus.DefaultGatherer).Client(recorder)
+	grouping, err := parseLabels("pushgateway grouping", config.Push.PushgatewayGrouping.Value())
+	if err != nil {
+		return permanentError{err: err}
+	}
+	// The grouping key is a set, the pusher orders its labels in the url as it likes
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if config.Push.BearerToken != "" {
+		pusher = pusher.Header(http.Header{"Authorization": []string{"Bearer " + config.Push.BearerToken}})
+	} else if config.Push.Username != "" {
+		pusher = pusher.BasicAuth(config.Push.Username, config.Push.Password)
+	}
+	if err := pusher.Push(); err != nil {
+		if recorder.statusCode/100 == 4 && recorder.statusCode != http.StatusTooManyRequests {
+			return permanentError{err: err}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package push
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+)
+
+// gatewayReceiver - pushgateway keeping the last push
+type gatewayReceiver struct {
+	mu     sync.Mutex
+	method string
+	path   string
+	header http.Header
+	body   string
+	status int
+}
+
+func (r *gatewayReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, _ := io.ReadAll(req.Body)
+	r.method, r.path, r.header, r.body = req.Method, req.URL.EscapedPath(), req.Header.Clone(), string(body)
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestPushgatewayPush(t *testing.T) {
+	setupMetrics()
+	resetPushConfig()
+	receiver := &gatewayReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	config.Push.PushgatewayURL = server.URL
+	config.Push.PushgatewayGrouping = *cli.NewStringSlice("site=ghes-prod", "env=prod")
+	config.Push.Username = "user"
+	config.Push.Password = "secret"
+
+	if err := pushgatewayPush(); err != nil {
+		t.Fatalf("pushgatewayPush: %v", err)
+	}
+	if receiver.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", receiver.method)
+	}
+	if want := []string{"/metrics/job/github-actions-exporter/env/prod/site/ghes-prod", "/metrics/job/github-actions-exporter/site/ghes-prod/env/prod"}; receiver.path != want[0] && receiver.path != want[1] {
+		t.Errorf("path = %s, want the grouping key env=prod, site=ghes-prod", receiver.path)
+	}
+	req := &http.Request{Header: receiver.header}
+	if username, password, ok := req.BasicAuth(); !ok || username != "user" || password != "secret" {
+		t.Errorf("basic auth = %q %q %v, want user secret", username, password, ok)
+	}
+	if !strings.Contains(receiver.body, "github_push_test_gauge") {
+		t.Error("github_push_test_gauge not pushed")
+	}
+}
+
+func TestPushgatewayPushBearerToken(t *testing.T) {
+	setupMetrics()
+	resetPushConfig()
+	receiver := &gatewayReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	config.Push.PushgatewayURL = server.URL
+	config.Push.BearerToken = "token"
+
+	if err := pushgatewayPush(); err != nil {
+		t.Fatalf("pushgatewayPush: %v", err)
+	}
+	if got := receiver.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want Bearer token", got)
+	}
+}
+
+func TestPushgatewayPushErrors(t *testing.T) {
+	setupMetrics()
+	for _, tc := range []struct {
+		name      string
+		status    int
+		grouping  string
+		permanent bool
+	}{
+		{"invalid grouping", 0, "instance", true},
+		{"4xx", http.StatusBadRequest, "", true},
+		{"429", http.StatusTooManyRequests, "", false},
+		{"5xx", http.StatusBadGateway, "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resetPushConfig()
+			receiver := &gatewayReceiver{status: tc.status}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			config.Push.PushgatewayURL = server.URL
+			if tc.grouping != "" {
+				config.Push.PushgatewayGrouping = *cli.NewStringSlice(tc.grouping)
+			}
+
+			err := pushgatewayPush()
+			if err == nil {
+				t.Fatal("pushgatewayPush succeeded")
+			}
+			if permanent := errors.As(err, &permanentError{}); permanent != tc.permanent {
+				t.Errorf("pushgatewayPush error = %v, permanent %v, want %v", err, permanent, tc.permanent)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package push
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/push/remotewritepb"
+)
+
+// pushClient - http client of the remote write and pushgateway pushes
+var pushClient = &http.Client{Timeout: 30 * time.Second}
+
+// newTimeSeries - one series made of the family labels, the metric labels, extra labels (e.g. le)
+// and the external labels the series doesn't have already
+func newTimeSeries(name string, metric *dto.Metric, value float64, timestamp int64, external map[string]string, extra ...string) *remotewritepb.TimeSeries {
+	labels := []*remotewritepb.Label{{Name: "__name__", Value: name}}
+	seen := map[string]bool{}
+	for _, pair := range metric.GetLabel() {
+		labels = append(labels, &remotewritepb.Label{Name: pair.GetName(), Value: pair.GetValue()})
+		seen[pair.GetName()] = true
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels = append(labels, &remotewritepb.Label{Name: extra[i], Value: extra[i+1]})
+		seen[extra[i]] = true
+	}
+	for name, value := range external {
+		if !seen[name] {
+			labels = append(labels, &remotewritepb.Label{Name: name, Value: value})
+		}
+	}
+	// The remote write spec requires labels sorted by name
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return &remotewritepb.TimeSeries{
+		Labels:  labels,
+		Samples: []*remotewritepb.Sample{{Value: value, Timestamp: timestamp}},
+	}
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// toTimeSeries - flatten gathered metric families into remote write series,
+// histograms and summaries are split like in the text exposition format
+func toTimeSeries(families []*dto.MetricFamily, timestamp int64, external map[string]string) []*remotewritepb.TimeSeries {
+	var series []*remotewritepb.TimeSeries
+	for _, family := range families {
+		name := family.GetName()
+		for _, metric := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series = append(series, newTimeSeries(name, metric, metric.GetCounter().GetValue(), timestamp, external))
+			case dto.MetricType_GAUGE:
+				series = append(series, newTimeSeries(name, metric, metric.GetGauge().GetValue(), timestamp, external))
+			case dto.MetricType_UNTYPED:
+				series = append(series, newTimeSeries(name, metric, metric.GetUntyped().GetValue(), timestamp, external))
+			case dto.MetricType_HISTOGRAM:
+				h := metric.GetHistogram()
+				for _, bucket := range h.GetBucket() {
+					series = append(series, newTimeSeries(name+"_bucket", metric, float64(bucket.GetCumulativeCount()), timestamp, external, "le", formatFloat(bucket.GetUpperBound())))
+				}
+				if buckets := h.GetBucket(); len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].GetUpperBound(), +1) {
+					series = append(series, newTimeSeries(name+"_bucket", metric, float64(h.GetSampleCount()), timestamp, external, "le", "+Inf"))
+				}
+				series = append(series, newTimeSeries(name+"_sum", metric, h.GetSampleSum(), timestamp, external))
+				series = append(series, newTimeSeries(name+"_count", metric, float64(h.GetSampleCount()), timestamp, external))
+			case dto.MetricType_SUMMARY:
+				s := metric.GetSummary()
+				for _, quantile := range s.GetQuantile() {
+					series = append(series, newTimeSeries(name, metric, quantile.GetValue(), timestamp, external, "quantile", formatFloat(quantile.GetQuantile())))
+				}
+				series = append(series, newTimeSeries(name+"_sum", metric, s.GetSampleSum(), timestamp, external))
+				series = append(series, newTimeSeries(name+"_count", metric, float64(s.GetSampleCount()), timestamp, external))
+			}
+		}
+	}
+	return series
+}
+
+// remoteWrite - send the metrics of the default registry with the remote write protocol (snappy protobuf)
+func remoteWrite() error {
+	external, err := parseLabels("remote write external label", config.Push.ExternalLabels.Value())
+	if err != nil {
+		return permanentError{err: err}
+	}
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics failed: %v", err)
+	}
+	data, err := proto.Marshal(&remotewritepb.WriteRequest{
+		Timeseries: toTimeSeries(families, time.Now().UnixMilli(), external),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", config.Push.RemoteWriteURL, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if config.Push.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Push.BearerToken)
+	} else if config.Push.Username != "" {
+		req.SetBasicAuth(config.Push.Username, config.Push.Password)
+	}
+
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return checkStatus("remote write", resp.StatusCode, resp.Status, body)
+}
@@ -0,0 +1,201 @@
+package push
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/push/remotewritepb"
+)
+
+var registerTestMetrics sync.Once
+
+// setupMetrics - register a gauge and a histogram in the default registry, the one pushed
+func setupMetrics() {
+	registerTestMetrics.Do(func() {
+		gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "github_push_test_gauge", Help: "test gauge"}, []string{"repo", "instance"})
+		gauge.WithLabelValues("foo/svc", "from-series").Set(3)
+		histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "github_push_test_seconds", Help: "test histogram", Buckets: []float64{1, 10}}, []string{"repo"})
+		histogram.WithLabelValues("foo/svc").Observe(0.5)
+		histogram.WithLabelValues("foo/svc").Observe(5)
+		histogram.WithLabelValues("foo/svc").Observe(50)
+		prometheus.MustRegister(gauge, histogram)
+	})
+}
+
+// writeReceiver - remote write receiver keeping the series and headers of the last request
+type writeReceiver struct {
+	mu       sync.Mutex
+	requests int
+	header   http.Header
+	series   []*remotewritepb.TimeSeries
+	// status - status codes of the successive responses, 204 once exhausted
+	status []int
+}
+
+func (r *writeReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	r.header = req.Header.Clone()
+
+	compressed, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var write remotewritepb.WriteRequest
+	if err := proto.Unmarshal(data, &write); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.series = write.GetTimeseries()
+
+	if len(r.status) > 0 {
+		status := r.status[0]
+		r.status = r.status[1:]
+		w.WriteHeader(status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// find - labels and value of the series with these labels
+func (r *writeReceiver) find(labels map[string]string) (map[string]string, float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.series {
+		got := map[string]string{}
+		for _, l := range s.GetLabels() {
+			got[l.GetName()] = l.GetValue()
+		}
+		match := true
+		for name, value := range labels {
+			if got[name] != value {
+				match = false
+				break
+			}
+		}
+		if match {
+			return got, s.GetSamples()[0].GetValue(), true
+		}
+	}
+	return nil, 0, false
+}
+
+func resetPushConfig() {
+	config.Push.RemoteWriteURL = ""
+	config.Push.PushgatewayURL = ""
+	config.Push.PushgatewayJob = "github-actions-exporter"
+	config.Push.PushgatewayGrouping = cli.StringSlice{}
+	config.Push.ExternalLabels = cli.StringSlice{}
+	config.Push.Username = ""
+	config.Push.Password = ""
+	config.Push.BearerToken = ""
+	config.Push.Retries = 0
+}
+
+func TestRemoteWrite(t *testing.T) {
+	setupMetrics()
+	resetPushConfig()
+	receiver := &writeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	config.Push.RemoteWriteURL = server.URL + "/api/v1/write"
+	config.Push.ExternalLabels = *cli.NewStringSlice("instance=ghes-prod", "env=prod")
+	config.Push.BearerToken = "token"
+
+	if err := remoteWrite(); err != nil {
+		t.Fatalf("remoteWrite: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"Authorization":                     "Bearer token",
+	} {
+		if got := receiver.header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+
+	for _, s := range receiver.series {
+		names := make([]string, 0, len(s.GetLabels()))
+		for _, l := range s.GetLabels() {
+			names = append(names, l.GetName())
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Errorf("labels not sorted: %v", names)
+		}
+	}
+
+	// A label of the series takes precedence over the external label
+	labels, value, ok := receiver.find(map[string]string{"__name__": "github_push_test_gauge"})
+	if !ok || value != 3 || labels["instance"] != "from-series" || labels["env"] != "prod" || labels["repo"] != "foo/svc" {
+		t.Errorf("github_push_test_gauge = %v %v, want 3 with instance=from-series, env=prod", labels, value)
+	}
+
+	for _, tc := range []struct {
+		labels map[string]string
+		want   float64
+	}{
+		{map[string]string{"__name__": "github_push_test_seconds_bucket", "le": "1"}, 1},
+		{map[string]string{"__name__": "github_push_test_seconds_bucket", "le": "10"}, 2},
+		{map[string]string{"__name__": "github_push_test_seconds_bucket", "le": "+Inf"}, 3},
+		{map[string]string{"__name__": "github_push_test_seconds_sum"}, 55.5},
+		{map[string]string{"__name__": "github_push_test_seconds_count"}, 3},
+	} {
+		labels, value, ok := receiver.find(tc.labels)
+		if !ok || value != tc.want {
+			t.Errorf("%v = %v (found %v), want %v", tc.labels, value, ok, tc.want)
+			continue
+		}
+		if labels["instance"] != "ghes-prod" || labels["env"] != "prod" || labels["repo"] != "foo/svc" {
+			t.Errorf("%v labels = %v, want the external labels", tc.labels, labels)
+		}
+	}
+}
+
+func TestRemoteWriteBasicAuth(t *testing.T) {
+	setupMetrics()
+	resetPushConfig()
+	receiver := &writeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	config.Push.RemoteWriteURL = server.URL
+	config.Push.Username = "user"
+	config.Push.Password = "secret"
+
+	if err := remoteWrite(); err != nil {
+		t.Fatalf("remoteWrite: %v", err)
+	}
+	req := &http.Request{Header: receiver.header}
+	if username, password, ok := req.BasicAuth(); !ok || username != "user" || password != "secret" {
+		t.Errorf("basic auth = %q %q %v, want user secret", username, password, ok)
+	}
+}
+
+func TestRemoteWriteInvalidExternalLabels(t *testing.T) {
+	resetPushConfig()
+	config.Push.RemoteWriteURL = "http://localhost:1"
+	config.Push.ExternalLabels = *cli.NewStringSlice("instance")
+	if err := remoteWrite(); !errors.As(err, &permanentError{}) {
+		t.Errorf("remoteWrite with an invalid external label error = %v, want a permanent error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+	"github.com/chipgata/github-actions-exporter/pkg/scaler/externalscaler"
+)
+
+const metricName = "github-job-queue"
+
+// Scaler - KEDA external scaler answering from the exporter's view of queued jobs.
+//
+// Supported scalerMetadata:
+//   - labels: comma separated runs-on labels of the jobs (e.g. self-hosted,linux)
+//   - org: organization of the jobs
+//   - runnerGroup: runner group of the jobs
+//   - targetJobsPerReplica: number of jobs handled by one replica (default 1)
+//   - includeInProgress: also count in progress jobs (default true)
+type Scaler struct {
+	externalscaler.UnimplementedExternalScalerServer
+
+	// countJobs - number of queued and in progress jobs matching a filter
+	countJobs func(filter metrics.JobQueueFilter) (queued int, inProgress int)
+}
+
+// NewScaler - scaler answering from the job queue of the exporter
+func NewScaler() *Scaler {
+	return &Scaler{countJobs: metrics.CountActiveJobs}
+}
+
+type scalerMetadata struct {
+	filter               metrics.JobQueueFilter
+	targetJobsPerReplica int64
+	includeInProgress    bool
+}
+
+func parseMetadata(ref *externalscaler.ScaledObjectRef) (scalerMetadata, error) {
+	m := scalerMetadata{targetJobsPerReplica: 1, includeInProgress: true}
+	if ref == nil {
+		return m, nil
+	}
+	metadata := ref.GetScalerMetadata()
+	if labels := metadata["labels"]; labels != "" {
+		m.filter.Labels = strings.Split(labels, ",")
+	}
+	m.filter.Org = metadata["org"]
+	m.filter.RunnerGroup = metadata["runnerGroup"]
+	if v := metadata["targetJobsPerReplica"]; v != "" {
+		target, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || target < 1 {
+			return m, fmt.Errorf("invalid targetJobsPerReplica %q", v)
+		}
+		m.targetJobsPerReplica = target
+	}
+	if v := metadata["includeInProgress"]; v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return m, fmt.Errorf("invalid includeInProgress %q", v)
+		}
+		m.includeInProgress = include
+	}
+	return m, nil
+}
+
+// jobs - number of jobs matching the metadata
+func (s *Scaler) jobs(m scalerMetadata) int64 {
+	queued, inProgress := s.countJobs(m.filter)
+	if m.includeInProgress {
+		return int64(queued + inProgress)
+	}
+	return int64(queued)
+}
+
+// IsActive - active when there is at least one matching job
+func (s *Scaler) IsActive(ctx context.Context, ref *externalscaler.ScaledObjectRef) (*externalscaler.IsActiveResponse, error) {
+	m, err := parseMetadata(ref)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &externalscaler.IsActiveResponse{Result: s.jobs(m) > 0}, nil
+}
+
+// StreamIsActive - push the active state on every job queue refresh, until KEDA closes the stream
+func (s *Scaler) StreamIsActive(ref *externalscaler.ScaledObjectRef, stream grpc.ServerStreamingServer[externalscaler.IsActiveResponse]) error {
+	m, err := parseMetadata(ref)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	ticker := time.NewTicker(time.Duration(config.Metrics.JobQueueRefresh) * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(&externalscaler.IsActiveResponse{Result: s.jobs(m) > 0}); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetMetricSpec - one metric, the number of matching jobs, with a target of targetJobsPerReplica
+func (s *Scaler) GetMetricSpec(ctx context.Context, ref *externalscaler.ScaledObjectRef) (*externalscaler.GetMetricSpecResponse, error) {
+	m, err := parseMetadata(ref)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &externalscaler.GetMetricSpecResponse{
+		MetricSpecs: []*externalscaler.MetricSpec{{
+			MetricName:      metricName,
+			TargetSize:      m.targetJobsPerReplica,
+			TargetSizeFloat: float64(m.targetJobsPerReplica),
+		}},
+	}, nil
+}
+
+// GetMetrics - return the number of matching jobs
+func (s *Scaler) GetMetrics(ctx context.Context, req *externalscaler.GetMetricsRequest) (*externalscaler.GetMetricsResponse, error) {
+	m, err := parseMetadata(req.GetScaledObjectRef())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	jobs := s.jobs(m)
+	return &externalscaler.GetMetricsResponse{
+		MetricValues: []*externalscaler.MetricValue{{
+			MetricName:       metricName,
+			MetricValue:      jobs,
+			MetricValueFloat: float64(jobs),
+		}},
+	}, nil
+}
+
+// RunScaler - serve the KEDA external scaler gRPC endpoint
+func RunScaler() error {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(config.KedaScalerPort))
+	if err != nil {
+		return fmt.Errorf("keda scaler listen failed: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	externalscaler.RegisterExternalScalerServer(grpcServer, NewScaler())
+
+	slog.Info("keda external scaler listening", "address", "0.0.0.0:"+strconv.Itoa(config.KedaScalerPort))
+	return grpcServer.Serve(listener)
+}
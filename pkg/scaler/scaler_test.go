@@ -0,0 +1,187 @@
+package scaler
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+	"github.com/chipgata/github-actions-exporter/pkg/scaler/externalscaler"
+)
+
+// fakeJobQueue - job counts by runs-on labels, recording the filters asked for
+type fakeJobQueue struct {
+	mu      sync.Mutex
+	counts  map[string][2]int
+	filters []metrics.JobQueueFilter
+}
+
+func (f *fakeJobQueue) count(filter metrics.JobQueueFilter) (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.filters = append(f.filters, filter)
+	counts := f.counts[strings.Join(filter.Labels, ",")]
+	return counts[0], counts[1]
+}
+
+func (f *fakeJobQueue) lastFilter() metrics.JobQueueFilter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.filters[len(f.filters)-1]
+}
+
+func newFakeJobQueue() *fakeJobQueue {
+	return &fakeJobQueue{counts: map[string][2]int{
+		"self-hosted,linux": {2, 1},
+		"windows":           {1, 0},
+		"macos":             {0, 0},
+		"gpu":               {0, 2},
+	}}
+}
+
+// startScaler - serve a Scaler counting the jobs of queue on an in-memory listener and return a client of it
+func startScaler(t *testing.T, queue *fakeJobQueue) externalscaler.ExternalScalerClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	externalscaler.RegisterExternalScalerServer(server, &Scaler{countJobs: queue.count})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return externalscaler.NewExternalScalerClient(conn)
+}
+
+func ref(metadata map[string]string) *externalscaler.ScaledObjectRef {
+	return &externalscaler.ScaledObjectRef{Name: "runners", Namespace: "ci", ScalerMetadata: metadata}
+}
+
+func TestIsActive(t *testing.T) {
+	queue := newFakeJobQueue()
+	client := startScaler(t, queue)
+
+	for _, tc := range []struct {
+		metadata map[string]string
+		want     bool
+	}{
+		{map[string]string{"labels": "self-hosted,linux"}, true},
+		{map[string]string{"labels": "windows"}, true},
+		{map[string]string{"labels": "macos"}, false},
+		{map[string]string{"labels": "gpu"}, true},
+		{map[string]string{"labels": "gpu", "includeInProgress": "false"}, false},
+	} {
+		resp, err := client.IsActive(context.Background(), ref(tc.metadata))
+		if err != nil {
+			t.Fatalf("IsActive(%v): %v", tc.metadata, err)
+		}
+		if resp.GetResult() != tc.want {
+			t.Errorf("IsActive(%v) = %v, want %v", tc.metadata, resp.GetResult(), tc.want)
+		}
+	}
+
+	_, err := client.IsActive(context.Background(), ref(map[string]string{"targetJobsPerReplica": "0"}))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("IsActive with an invalid targetJobsPerReplica error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGetMetricSpec(t *testing.T) {
+	client := startScaler(t, newFakeJobQueue())
+
+	for _, tc := range []struct {
+		target string
+		want   int64
+	}{
+		{"", 1},
+		{"5", 5},
+	} {
+		resp, err := client.GetMetricSpec(context.Background(), ref(map[string]string{"targetJobsPerReplica": tc.target}))
+		if err != nil {
+			t.Fatalf("GetMetricSpec(%q): %v", tc.target, err)
+		}
+		specs := resp.GetMetricSpecs()
+		if len(specs) != 1 || specs[0].GetMetricName() != metricName || specs[0].GetTargetSize() != tc.want || specs[0].GetTargetSizeFloat() != float64(tc.want) {
+			t.Errorf("GetMetricSpec(%q) = %v, want one %s spec with target %d", tc.target, specs, metricName, tc.want)
+		}
+	}
+
+	for _, target := range []string{"0", "-1", "two"} {
+		_, err := client.GetMetricSpec(context.Background(), ref(map[string]string{"targetJobsPerReplica": target}))
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetMetricSpec(%q) error = %v, want InvalidArgument", target, err)
+		}
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	queue := newFakeJobQueue()
+	client := startScaler(t, queue)
+
+	for _, tc := range []struct {
+		metadata map[string]string
+		filter   metrics.JobQueueFilter
+		want     int64
+	}{
+		{map[string]string{"labels": "self-hosted,linux"}, metrics.JobQueueFilter{Labels: []string{"self-hosted", "linux"}}, 3},
+		{map[string]string{"labels": "self-hosted,linux", "includeInProgress": "false"}, metrics.JobQueueFilter{Labels: []string{"self-hosted", "linux"}}, 2},
+		{map[string]string{"labels": "windows", "org": "foo", "runnerGroup": "default"}, metrics.JobQueueFilter{Org: "foo", Labels: []string{"windows"}, RunnerGroup: "default"}, 1},
+		{map[string]string{}, metrics.JobQueueFilter{}, 0},
+	} {
+		resp, err := client.GetMetrics(context.Background(), &externalscaler.GetMetricsRequest{ScaledObjectRef: ref(tc.metadata), MetricName: metricName})
+		if err != nil {
+			t.Fatalf("GetMetrics(%v): %v", tc.metadata, err)
+		}
+		values := resp.GetMetricValues()
+		if len(values) != 1 || values[0].GetMetricName() != metricName || values[0].GetMetricValue() != tc.want || values[0].GetMetricValueFloat() != float64(tc.want) {
+			t.Errorf("GetMetrics(%v) = %v, want %d", tc.metadata, values, tc.want)
+		}
+		if filter := queue.lastFilter(); !reflect.DeepEqual(filter, tc.filter) {
+			t.Errorf("GetMetrics(%v) filter = %+v, want %+v", tc.metadata, filter, tc.filter)
+		}
+	}
+
+	_, err := client.GetMetrics(context.Background(), &externalscaler.GetMetricsRequest{ScaledObjectRef: ref(map[string]string{"includeInProgress": "maybe"})})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetMetrics with an invalid includeInProgress error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestStreamIsActive(t *testing.T) {
+	config.Metrics.JobQueueRefresh = 3600
+	client := startScaler(t, newFakeJobQueue())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.StreamIsActive(ctx, ref(map[string]string{"labels": "windows"}))
+	if err != nil {
+		t.Fatalf("StreamIsActive: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("first message: %v", err)
+	}
+	if !resp.GetResult() {
+		t.Errorf("first message = %v, want active", resp.GetResult())
+	}
+
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("Recv after cancel error = %v, want Canceled", err)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+const (
+	apiDefaultPerPage = 100
+	apiMaxPerPage     = 1000
+)
+
+// apiPage - paginated API response, items are keyed by kind (runners, runs or jobs)
+type apiPage map[string]interface{}
+
+// apiFilter - query string filters of an API endpoint. A filter is only applied when its
+// query arg is set, string comparisons are case insensitive.
+type apiFilter struct {
+	args *fasthttp.Args
+}
+
+func (f apiFilter) match(name string, value string) bool {
+	if !f.args.Has(name) {
+		return true
+	}
+	return strings.EqualFold(string(f.args.Peek(name)), value)
+}
+
+func (f apiFilter) matchAny(name string, values []string) bool {
+	if !f.args.Has(name) {
+		return true
+	}
+	wanted := string(f.args.Peek(name))
+	for _, v := range values {
+		if strings.EqualFold(wanted, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate - select the requested page of items and write it with the total count
+func paginate[T any](ctx *fasthttp.RequestCtx, kind string, items []T) {
+	page, err := queryInt(ctx, "page", 1)
+	if err != nil || page < 1 {
+		apiError(ctx, fasthttp.StatusBadRequest, "page must be a positive integer")
+		return
+	}
+	perPage, err := queryInt(ctx, "per_page", apiDefaultPerPage)
+	if err != nil || perPage < 1 || perPage > apiMaxPerPage {
+		apiError(ctx, fasthttp.StatusBadRequest, "per_page must be between 1 and "+strconv.Itoa(apiMaxPerPage))
+		return
+	}
+
+	start := (page - 1) * perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+
+	writeJSON(ctx, fasthttp.StatusOK, apiPage{
+		"total_count": len(items),
+		"page":        page,
+		"per_page":    perPage,
+		kind:          items[start:end],
+	})
+}
+
+func queryInt(ctx *fasthttp.RequestCtx, name string, defaultValue int) (int, error) {
+	if !ctx.QueryArgs().Has(name) {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(string(ctx.QueryArgs().Peek(name)))
+}
+
+func writeJSON(ctx *fasthttp.RequestCtx, status int, v interface{}) {
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(status)
+	if err := json.NewEncoder(ctx).Encode(v); err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+	}
+}
+
+func apiError(ctx *fasthttp.RequestCtx, status int, message string) {
+	writeJSON(ctx, status, map[string]string{"message": message})
+}
+
+// apiRunnersHandler - GET /api/v1/runners?scope=&owner=&name=&status=&busy=&label=
+func apiRunnersHandler(ctx *fasthttp.RequestCtx) {
+	f := apiFilter{ctx.QueryArgs()}
+	result := []metrics.Runner{}
+	for _, r := range metrics.Runners() {
+		if f.match("scope", r.Scope) && f.match("owner", r.Owner) && f.match("name", r.Name) &&
+			f.match("status", r.Status) && f.match("busy", strconv.FormatBool(r.Busy)) && f.matchAny("label", r.Labels) {
+			result = append(result, r)
+		}
+	}
+	paginate(ctx, "runners", result)
+}
+
+// apiRunsHandler - GET /api/v1/runs?org=&repo=&workflow=&branch=&event=&status=&conclusion=
+func apiRunsHandler(ctx *fasthttp.RequestCtx) {
+	f := apiFilter{ctx.QueryArgs()}
+	result := []metrics.Run{}
+	for _, r := range metrics.Runs() {
+		if f.match("org", r.Org) && f.match("repo", r.Repo) && f.match("workflow", r.Name) && f.match("branch", r.HeadBranch) &&
+			f.match("event", r.Event) && f.match("status", r.Status) && f.match("conclusion", r.Conclusion) {
+			result = append(result, r)
+		}
+	}
+	paginate(ctx, "runs", result)
+}
+
+// apiJobsHandler - GET /api/v1/jobs?org=&repo=&run_id=&status=&conclusion=&label=&runner_group=&runner_name=
+func apiJobsHandler(ctx *fasthttp.RequestCtx) {
+	f := apiFilter{ctx.QueryArgs()}
+	result := []metrics.Job{}
+	for _, j := range metrics.Jobs() {
+		if f.match("org", j.Org) && f.match("repo", j.Repo) && f.match("run_id", strconv.FormatInt(j.RunID, 10)) &&
+			f.match("status", j.Status) && f.match("conclusion", j.Conclusion) && f.matchAny("label", j.Labels) &&
+			f.match("runner_group", j.RunnerGroup) && f.match("runner_name", j.RunnerName) {
+			result = append(result, j)
+		}
+	}
+	paginate(ctx, "jobs", result)
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+// CheckAccessCommand - check-access subcommand, verify the credentials can call what the collectors need
+func CheckAccessCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "check-access",
+		Usage:  "Call each Github endpoint the enabled collectors need and report missing permissions",
+		Action: RunCheckAccess,
+	}
+}
+
+// RunCheckAccess - print the access check table, exit status is non-zero if a required call failed
+func RunCheckAccess(ctx *cli.Context) error {
+	if err := config.Load(); err != nil {
+		return err
+	}
+	return metrics.CheckAccess(os.Stdout)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/common/expfmt"
+	"github.com/urfave/cli/v2"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+// CollectCommand - collect subcommand, write metrics to stdout or a file instead of serving them
+func CollectCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "collect",
+		Usage: "Run the enabled collectors and write metrics in Prometheus text format",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "once",
+				Usage: "Collect a single time and exit, instead of collecting every refresh",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Value:   "-",
+				Usage:   "File to write metrics to, - for stdout. The file is replaced atomically, for node_exporter's textfile collector",
+			},
+		},
+		Action: RunCollect,
+	}
+}
+
+// RunCollect - run the enabled collectors and write the metrics, exit status is non-zero on collection errors
+func RunCollect(ctx *cli.Context) error {
+	if err := config.Load(); err != nil {
+		return err
+	}
+	// Only Github metrics are of interest in a textfile, drop the exporter's own runtime metrics
+	prometheus.Unregister(collectors.NewGoCollector())
+	prometheus.Unregister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	if err := metrics.InitCollect(); err != nil {
+		return err
+	}
+
+	for {
+		collectErr := metrics.CollectOnce()
+		if err := writeMetrics(ctx.String("output")); err != nil {
+			return err
+		}
+		if ctx.Bool("once") {
+			return collectErr
+		}
+		if collectErr != nil {
+			slog.Error("collection failed", "error", collectErr)
+		}
+		time.Sleep(time.Duration(config.Github.Refresh) * time.Second)
+	}
+}
+
+// writeMetrics - write gathered metrics to output, through a temporary file renamed over output
+// so that readers never see a partial file
+func writeMetrics(output string) error {
+	if output == "-" {
+		return encodeMetrics(os.Stdout)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := encodeMetrics(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), output)
+}
+
+func encodeMetrics(w io.Writer) error {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return err
+	}
+	enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+
+	"github.com/chipgata/github-actions-exporter/pkg/logging"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+// debugCollector - collector state, as dumped by /debug/collectors
+type debugCollector struct {
+	Name         string    `json:"name"`
+	Enabled      bool      `json:"enabled"`
+	Refresh      string    `json:"refresh"`
+	LastCollect  time.Time `json:"last_collect"`
+	LastDuration string    `json:"last_duration"`
+	LastSuccess  time.Time `json:"last_success"`
+	Stale        bool      `json:"stale"`
+}
+
+// debugState - exporter state, as dumped by /debug/collectors
+type debugState struct {
+	Collectors   []debugCollector   `json:"collectors"`
+	Repositories []string           `json:"repositories"`
+	RateLimit    metrics.RateLimit  `json:"rate_limit"`
+	Errors       []metrics.APIError `json:"errors"`
+	LogLevel     string             `json:"log_level"`
+}
+
+// debugRouter - pprof and runtime endpoints, served on the debug listener only
+func debugRouter() *router.Router {
+	r := router.New()
+	r.GET("/debug/pprof/", pprofHandlerIndex)
+	r.GET("/debug/pprof/cmdline", pprofHandlerCmdline)
+	r.GET("/debug/pprof/profile", pprofHandlerProfile)
+	r.GET("/debug/pprof/symbol", pprofHandlerSymbol)
+	r.POST("/debug/pprof/symbol", pprofHandlerSymbol)
+	r.GET("/debug/pprof/trace", pprofHandlerTrace)
+	r.GET("/debug/pprof/{profile}", pprofHandlerIndex)
+
+	r.GET("/debug/collectors", debugCollectorsHandler)
+	r.GET("/debug/log_level", debugLogLevelHandler)
+	r.PUT("/debug/log_level", debugSetLogLevelHandler)
+	r.POST("/debug/log_level", debugSetLogLevelHandler)
+	return r
+}
+
+// debugCollectorsHandler - GET /debug/collectors, dump the state of the collectors
+func debugCollectorsHandler(ctx *fasthttp.RequestCtx) {
+	state := debugState{
+		Repositories: metrics.Repositories(),
+		RateLimit:    metrics.GetRateLimit(),
+		Errors:       metrics.RecentAPIErrors(),
+		LogLevel:     strings.ToLower(logging.Level.Level().String()),
+	}
+	for _, c := range metrics.Collectors() {
+		state.Collectors = append(state.Collectors, debugCollector{
+			Name:         c.Name,
+			Enabled:      c.Enabled,
+			Refresh:      c.Refresh.String(),
+			LastCollect:  c.LastCollect,
+			LastDuration: c.LastDuration.String(),
+			LastSuccess:  c.LastSuccess,
+			Stale:        c.Stale,
+		})
+	}
+	writeJSON(ctx, fasthttp.StatusOK, state)
+}
+
+// debugLogLevelHandler - GET /debug/log_level, return the current log level
+func debugLogLevelHandler(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, fasthttp.StatusOK, map[string]string{"level": strings.ToLower(logging.Level.Level().String())})
+}
+
+// debugSetLogLevelHandler - PUT /debug/log_level?level=debug (or the level as body), change the log level
+func debugSetLogLevelHandler(ctx *fasthttp.RequestCtx) {
+	level := string(ctx.QueryArgs().Peek("level"))
+	if level == "" {
+		level = string(ctx.PostBody())
+	}
+	if err := logging.SetLevel(level); err != nil {
+		apiError(ctx, fasthttp.StatusBadRequest, err.Error())
+		return
+	}
+	slog.Info("log level changed", "level", strings.ToLower(logging.Level.Level().String()))
+	debugLogLevelHandler(ctx)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+// configWatchInterval - how often the config files are checked for changes
+const configWatchInterval = 10 * time.Second
+
+// reloadMu - one configuration reload at a time
+var reloadMu sync.Mutex
+
+// reloadConfig - read and validate the configuration, and apply it. If it is invalid, the
+// configuration in use is kept.
+func reloadConfig(reason string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	reloadable, err := config.ReadReloadable()
+	if err != nil {
+		slog.Error("configuration reload failed, keeping the previous configuration", "reason", reason, "error", err)
+		metrics.RecordConfigReload(false)
+		return err
+	}
+	previousOrgs := config.Organizations()
+	config.Apply(reloadable)
+	metrics.RecordConfigReload(true)
+	slog.Info("configuration reloaded", "reason", reason)
+	go metrics.ReloadTargets(previousOrgs)
+	return nil
+}
+
+// watchConfig - reload the configuration on SIGHUP and when one of the config files changes
+func watchConfig() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	stamp, _ := filesStamp(config.WatchedFiles()...)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hup:
+			reloadConfig("SIGHUP")
+		case <-ticker.C:
+			current, err := filesStamp(config.WatchedFiles()...)
+			if err != nil || current == stamp {
+				continue
+			}
+			stamp = current
+			reloadConfig("config file changed")
+		}
+	}
+}
+
+// reloadHandler - POST /-/reload, reload the configuration.
+// Only served when authentication is configured, anyone could trigger reloads otherwise.
+func reloadHandler(ctx *fasthttp.RequestCtx) {
+	if !config.Web().AuthEnabled() {
+		apiError(ctx, fasthttp.StatusForbidden, "reload endpoint disabled, it needs basic auth users or bearer tokens in the web config")
+		return
+	}
+	if err := reloadConfig("reload endpoint"); err != nil {
+		apiError(ctx, fasthttp.StatusBadRequest, fmt.Sprintf("configuration reload failed: %v", err))
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, map[string]string{"status": "reloaded"})
+}
@@ -5,6 +5,7 @@ import (
 	rtp "runtime/pprof"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttpadaptor"
@@ -18,9 +19,13 @@ var (
 	index   = fasthttpadaptor.NewFastHTTPHandlerFunc(pprof.Index)
 )
 
-// prometheusHandler - fastHTTP handler for prometheus metrics
+// prometheusHandler - fastHTTP handler for prometheus metrics.
+// OpenMetrics is negotiated with the Accept header, it is needed to expose exemplars.
 func prometheusHandler() fasthttp.RequestHandler {
-	return fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+	return fasthttpadaptor.NewFastHTTPHandler(promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	))
 }
 
 func pprofHandlerIndex(ctx *fasthttp.RequestCtx) {
@@ -1,8 +1,9 @@
 package server
 
 import (
-	"log"
-	"strconv"
+	"context"
+	"log/slog"
+	"os"
 
 	"github.com/fasthttp/router"
 	"github.com/urfave/cli/v2"
@@ -10,26 +11,78 @@ import (
 
 	"github.com/chipgata/github-actions-exporter/pkg/config"
 	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+	"github.com/chipgata/github-actions-exporter/pkg/otlp"
+	"github.com/chipgata/github-actions-exporter/pkg/push"
+	"github.com/chipgata/github-actions-exporter/pkg/scaler"
 )
 
 // RunServer - run http server for expose metrics
 func RunServer(ctx *cli.Context) error {
+	if err := config.Load(); err != nil {
+		return err
+	}
+	if config.Push.RemoteWriteURL != "" || config.Push.PushgatewayURL != "" {
+		push.RunPusher()
+	}
 	metrics.InitMetrics()
+	metrics.RecordConfigReload(true)
+	go watchConfig()
+
+	if config.OTLP.Endpoint != "" {
+		shutdown, err := otlp.RunMetricsExporter(context.Background(), ctx.App.Version)
+		if err != nil {
+			return err
+		}
+		defer shutdown(context.Background())
+
+		if config.OTLP.Traces {
+			shutdownTraces, err := otlp.RunTraceExporter(context.Background(), ctx.App.Version)
+			if err != nil {
+				return err
+			}
+			defer shutdownTraces(context.Background())
+		}
+	}
+
+	if config.KedaScalerPort > 0 {
+		if !config.Metrics.FetchJobQueue {
+			slog.Warn("keda external scaler enabled without fetch_job_queue, it will always report 0 jobs")
+		}
+		go func() {
+			if err := scaler.RunScaler(); err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+		}()
+	}
 
 	r := router.New()
-	r.GET("/", func(ctx *fasthttp.RequestCtx) {
-		ctx.WriteString("/metrics")
-	})
+	r.GET("/", statusHandler(ctx.App.Version))
 	r.GET("/metrics", prometheusHandler())
+	r.GET("/api/v1/runners", apiRunnersHandler)
+	r.GET("/api/v1/runs", apiRunsHandler)
+	r.GET("/api/v1/jobs", apiJobsHandler)
+	r.POST("/-/reload", reloadHandler)
+	r.PUT("/-/reload", reloadHandler)
 
 	if config.Debug {
-		r.GET("/debug/pprof/", pprofHandlerIndex)
-		r.GET("/debug/pprof/cmdline", pprofHandlerCmdline)
-		r.GET("/debug/pprof/profile", pprofHandlerIndex)
-		r.GET("/debug/pprof/trace", pprofHandlerTrace)
-		r.GET("/debug/pprof/{profile}", pprofHandlerIndex)
+		debugListener, err := listen(config.DebugListenAddress, config.DebugWeb)
+		if err != nil {
+			return err
+		}
+		slog.Info("debug endpoints listening", "address", config.DebugListenAddress)
+		go func() {
+			if err := fasthttp.Serve(debugListener, newAuthenticator(config.DebugWeb).middleware(debugRouter().Handler)); err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+		}()
 	}
 
-	log.Print("exporter listening on 0.0.0.0:" + strconv.Itoa(config.Port))
-	return fasthttp.ListenAndServe(":"+strconv.Itoa(config.Port), r.Handler)
+	listener, err := listen(listenAddress(), config.Web)
+	if err != nil {
+		return err
+	}
+	slog.Info("exporter listening", "address", listenAddress())
+	return fasthttp.Serve(listener, newAuthenticator(config.Web).middleware(r.Handler))
 }
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/metrics"
+)
+
+// statusMaxQueuedJobs - number of jobs waiting for a runner listed on the status page, oldest first
+const statusMaxQueuedJobs = 100
+
+// runnerCount - online / busy / offline runners of an owner and runner group
+type runnerCount struct {
+	Scope       string
+	Owner       string
+	RunnerGroup string
+	Online      int
+	Busy        int
+	Offline     int
+}
+
+// statusPage - everything shown on the status page
+type statusPage struct {
+	Version         string
+	Now             time.Time
+	Organizations   []string
+	Repositories    []string
+	Monitored       []string
+	Collectors      []metrics.CollectorStatus
+	RateLimit       metrics.RateLimit
+	Runners         []runnerCount
+	QueuedJobs      []metrics.Job
+	QueuedJobsTotal int
+	JobQueueEnabled bool
+	Errors          []metrics.APIError
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"ago": func(now time.Time, t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return now.Sub(t).Round(time.Second).String() + " ago"
+	},
+	"in": func(now time.Time, t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Sub(now).Round(time.Second).String()
+	},
+	"round": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>github-actions-exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #24292f; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 1.5em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: left; }
+th { background: #f6f8fa; }
+.muted { color: #57606a; }
+.bad { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>github-actions-exporter <span class="muted">{{.Version}}</span></h1>
+<p><a href="/metrics">/metrics</a> &middot; <a href="/api/v1/runners">/api/v1/runners</a> &middot; <a href="/api/v1/runs">/api/v1/runs</a> &middot; <a href="/api/v1/jobs">/api/v1/jobs</a></p>
+
+<h2>Configuration</h2>
+<table>
+<tr><th>Organizations</th><td>{{if .Organizations}}{{join .Organizations ", "}}{{else}}<span class="muted">none</span>{{end}}</td></tr>
+<tr><th>Repositories</th><td>{{if .Repositories}}{{join .Repositories ", "}}{{else}}<span class="muted">all repositories of the organizations</span>{{end}}</td></tr>
+<tr><th>Monitored</th><td><details><summary>{{len .Monitored}} repositories</summary>{{join .Monitored ", "}}</details></td></tr>
+</table>
+
+<h2>Collectors</h2>
+<table>
+<tr><th>Collector</th><th>Enabled</th><th>Refresh</th><th>Last collection</th><th>Duration</th><th>Last success</th></tr>
+{{range .Collectors}}<tr><td>{{.Name}}</td><td>{{.Enabled}}</td><td>{{.Refresh}}</td><td>{{if .Enabled}}{{ago $.Now .LastCollect}}{{else}}-{{end}}</td><td>{{if not .LastCollect.IsZero}}{{round .LastDuration}}{{else}}-{{end}}</td><td{{if .Stale}} class="bad"{{end}}>{{if .LastSuccess.IsZero}}-{{else}}{{ago $.Now .LastSuccess}}{{end}}{{if .Stale}} (stale){{end}}</td></tr>
+{{end}}</table>
+
+<h2>Rate limit</h2>
+{{if .RateLimit.CheckedAt.IsZero}}<p class="muted">Not checked yet.</p>{{else}}
+<table>
+<tr><th>Remaining</th><td{{if lt .RateLimit.Remaining 500}} class="bad"{{end}}>{{.RateLimit.Remaining}} / {{.RateLimit.Limit}}</td></tr>
+<tr><th>Reset in</th><td>{{in .Now .RateLimit.Reset}}</td></tr>
+<tr><th>Checked</th><td>{{ago .Now .RateLimit.CheckedAt}}</td></tr>
+</table>{{end}}
+
+<h2>Runners</h2>
+{{if .Runners}}<table>
+<tr><th>Scope</th><th>Owner</th><th>Runner group</th><th>Online</th><th>Busy</th><th>Offline</th></tr>
+{{range .Runners}}<tr><td>{{.Scope}}</td><td>{{.Owner}}</td><td>{{.RunnerGroup}}</td><td>{{.Online}}</td><td>{{.Busy}}</td><td{{if .Offline}} class="bad"{{end}}>{{.Offline}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">No runners.</p>{{end}}
+
+<h2>Queued jobs</h2>
+{{if not .JobQueueEnabled}}<p class="muted">fetch_job_queue is not enabled, only jobs of runs of the last hour are shown.</p>{{end}}
+{{if .QueuedJobs}}<p>{{.QueuedJobsTotal}} queued jobs{{if gt .QueuedJobsTotal (len .QueuedJobs)}}, the {{len .QueuedJobs}} oldest are shown{{end}}.</p>
+<table>
+<tr><th>Repository</th><th>Workflow</th><th>Job</th><th>Status</th><th>Labels</th><th>Queued</th></tr>
+{{range .QueuedJobs}}<tr><td>{{.Repo}}</td><td>{{.WorkflowName}}</td><td>{{if .HTMLURL}}<a href="{{.HTMLURL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td>{{.Status}}</td><td>{{join .Labels ", "}}</td><td>{{ago $.Now .CreatedAt}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">No queued jobs.</p>{{end}}
+
+<h2>Recent errors</h2>
+{{if .Errors}}<table>
+<tr><th>Time</th><th>Endpoint</th><th>Error</th></tr>
+{{range .Errors}}<tr><td>{{ago $.Now .Time}}</td><td>{{.Endpoint}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">No errors.</p>{{end}}
+</body>
+</html>
+`))
+
+// countRunners - online / busy / offline runners by scope, owner and runner group
+func countRunners(runners []metrics.Runner) []runnerCount {
+	counts := map[[3]string]*runnerCount{}
+	var result []runnerCount
+	for _, r := range runners {
+		key := [3]string{r.Scope, r.Owner, r.RunnerGroup}
+		c, ok := counts[key]
+		if !ok {
+			c = &runnerCount{Scope: r.Scope, Owner: r.Owner, RunnerGroup: r.RunnerGroup}
+			counts[key] = c
+		}
+		if r.Status != "online" {
+			c.Offline++
+			continue
+		}
+		c.Online++
+		if r.Busy {
+			c.Busy++
+		}
+	}
+	for _, c := range counts {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Scope != result[j].Scope {
+			return result[i].Scope < result[j].Scope
+		}
+		if result[i].Owner != result[j].Owner {
+			return result[i].Owner < result[j].Owner
+		}
+		return result[i].RunnerGroup < result[j].RunnerGroup
+	})
+	return result
+}
+
+// statusHandler - server-rendered status page, without external assets
+func statusHandler(version string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		page := statusPage{
+			Version:         version,
+			Now:             time.Now(),
+			Organizations:   config.Organizations(),
+			Repositories:    config.Repositories(),
+			Monitored:       metrics.Repositories(),
+			Collectors:      metrics.Collectors(),
+			RateLimit:       metrics.GetRateLimit(),
+			Runners:         countRunners(metrics.Runners()),
+			JobQueueEnabled: config.Metrics.FetchJobQueue,
+			Errors:          metrics.RecentAPIErrors(),
+		}
+
+		var queued []metrics.Job
+		for _, j := range metrics.Jobs() {
+			if metrics.IsWaitingJobStatus(j.Status) {
+				queued = append(queued, j)
+			}
+		}
+		// Jobs are newest first, the oldest queued jobs are the ones on-call is after
+		sort.SliceStable(queued, func(i, j int) bool { return queued[i].CreatedAt.Before(queued[j].CreatedAt) })
+		page.QueuedJobsTotal = len(queued)
+		if len(queued) > statusMaxQueuedJobs {
+			queued = queued[:statusMaxQueuedJobs]
+		}
+		page.QueuedJobs = queued
+
+		var body bytes.Buffer
+		if err := statusTemplate.Execute(&body, page); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.SetContentType("text/html; charset=utf-8")
+		ctx.SetBody(body.Bytes())
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/chipgata/github-actions-exporter/pkg/config"
+	"github.com/chipgata/github-actions-exporter/pkg/logging"
+)
+
+var (
+	clientAuthTypes = map[string]tls.ClientAuthType{
+		"":                           tls.NoClientCert,
+		"NoClientCert":               tls.NoClientCert,
+		"RequestClientCert":          tls.RequestClientCert,
+		"RequireAnyClientCert":       tls.RequireAnyClientCert,
+		"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+		"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+	}
+	tlsVersions = map[string]uint16{
+		"":      tls.VersionTLS12,
+		"TLS10": tls.VersionTLS10,
+		"TLS11": tls.VersionTLS11,
+		"TLS12": tls.VersionTLS12,
+		"TLS13": tls.VersionTLS13,
+	}
+)
+
+// listenAddress - configured listen address, 0.0.0.0 and the exporter port by default
+func listenAddress() string {
+	if config.ListenAddress != "" {
+		return config.ListenAddress
+	}
+	return ":" + strconv.Itoa(config.Port)
+}
+
+// listen - open a listener on a TCP address or a unix:/path socket, wrapped in TLS when
+// webConfig has a tls_server_config at start. TLS can't be switched on or off by a reload.
+func listen(address string, webConfig func() config.WebConfig) (net.Listener, error) {
+	var listener net.Listener
+	var err error
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		path = strings.TrimPrefix(path, "//")
+		// Remove the socket left behind by a previous run, it would make Listen fail
+		if info, statErr := os.Stat(path); statErr == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		listener, err = net.Listen("unix", path)
+	} else {
+		listener, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tlsServerConfig := webConfig().TLSServerConfig
+	if tlsServerConfig == nil {
+		return listener, nil
+	}
+	reloader := &tlsReloader{webConfig: webConfig, started: tlsServerConfig}
+	// Load once now, so that a wrong TLS configuration fails at start rather than on the first scrape
+	if _, err := reloader.getConfigForClient(nil); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return tls.NewListener(listener, &tls.Config{GetConfigForClient: reloader.getConfigForClient}), nil
+}
+
+// tlsReloader - TLS configuration reloaded when the web config or the certificate, key or
+// client CA files change
+type tlsReloader struct {
+	webConfig func() config.WebConfig
+	started   *config.TLSServerConfig
+
+	mu     sync.Mutex
+	stamp  string
+	loaded *tls.Config
+}
+
+// filesStamp - modification time and size of each file, changes when one of them is rewritten
+func filesStamp(files ...string) (string, error) {
+	var stamp strings.Builder
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&stamp, "%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp.String(), nil
+}
+
+// getConfigForClient - return the TLS configuration, reloading it if a file changed since the
+// last handshake. If reloading fails, the last good configuration is kept.
+func (r *tlsReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	tlsServerConfig := r.webConfig().TLSServerConfig
+	if tlsServerConfig == nil {
+		tlsServerConfig = r.started
+	}
+	stamp, err := filesStamp(tlsServerConfig.CertFile, tlsServerConfig.KeyFile, tlsServerConfig.ClientCAFile)
+	stamp += tlsServerConfig.ClientAuthType + ";" + tlsServerConfig.MinVersion
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil && r.loaded != nil && stamp == r.stamp {
+		return r.loaded, nil
+	}
+	if err == nil {
+		var loaded *tls.Config
+		if loaded, err = buildTLSConfig(tlsServerConfig); err == nil {
+			if r.loaded != nil {
+				slog.Info("TLS configuration reloaded")
+			}
+			r.stamp, r.loaded = stamp, loaded
+			return loaded, nil
+		}
+	}
+	if r.loaded == nil {
+		return nil, err
+	}
+	logging.ErrorLimited("tls reload", "TLS configuration reload failed, keeping the previous one", "error", err)
+	r.stamp = stamp
+	return r.loaded, nil
+}
+
+func buildTLSConfig(c *config.TLSServerConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate failed: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuthTypes[c.ClientAuthType],
+		MinVersion:   tlsVersions[c.MinVersion],
+	}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA failed: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA %s", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// authenticator - basic auth and bearer token check of every request
+type authenticator struct {
+	web func() config.WebConfig
+
+	// verified - successful basic auth credentials, bcrypt is too slow to run on every scrape
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]bool
+	// dummyHash - compared against for unknown users, so that they take as long as known ones
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func newAuthenticator(web func() config.WebConfig) *authenticator {
+	return &authenticator{web: web, verified: map[[sha256.Size]byte]bool{}}
+}
+
+func (a *authenticator) checkBasicAuth(web config.WebConfig, user string, password string) bool {
+	hash, ok := web.BasicAuthUsers[user]
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+
+	a.mu.Lock()
+	verified := a.verified[key]
+	a.mu.Unlock()
+	if verified {
+		return true
+	}
+
+	if !ok {
+		a.dummyOnce.Do(func() {
+			a.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	a.mu.Lock()
+	a.verified[key] = true
+	a.mu.Unlock()
+	return true
+}
+
+func (a *authenticator) checkBearerToken(web config.WebConfig, token string) bool {
+	for _, t := range web.BearerTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *authenticator) authorized(web config.WebConfig, ctx *fasthttp.RequestCtx) bool {
+	authorization := string(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization))
+	scheme, credentials, _ := strings.Cut(authorization, " ")
+	switch {
+	case strings.EqualFold(scheme, "Basic") && len(web.BasicAuthUsers) > 0:
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return false
+		}
+		user, password, ok := strings.Cut(string(decoded), ":")
+		return ok && a.checkBasicAuth(web, user, password)
+	case strings.EqualFold(scheme, "Bearer") && len(web.BearerTokens) > 0:
+		return a.checkBearerToken(web, credentials)
+	}
+	return false
+}
+
+// middleware - reject requests without valid credentials, when authentication is configured
+func (a *authenticator) middleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		web := a.web()
+		if web.AuthEnabled() && !a.authorized(web, ctx) {
+			if len(web.BasicAuthUsers) > 0 {
+				ctx.Response.Header.Set(fasthttp.HeaderWWWAuthenticate, `Basic realm="github-actions-exporter"`)
+			}
+			ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+			return
+		}
+		next(ctx)
+	}
+}